@@ -1,35 +1,82 @@
 package zgok
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Builder interface.
 type Builder interface {
 	SetExePath(exePath string) error
 	AddZipPath(zipPath string) error
+	AddZipPathAs(zipPath, destPath string, excludes ...string) error
+	AddFileSystem(fs FileSystem)
+	AddExcludes(patterns ...string) error
+	SetCompression(method uint16) error
+	SetSymlinkMode(mode SymlinkMode)
+	SetMetadata(key, value string)
 	SetOutPath(outPath string)
+	SetMaxSize(maxSize int64)
+	SetPrecompress(patterns ...string) error
+	SetFingerprint(patterns ...string) error
+	ApplyConfig(config *BuildConfig) error
 	Build() error
+	BuildPack() error
+	Report() *BuildReport
 }
 
 // Zgok builder
 type zgokBuilder struct {
-	exePath  string   // Executable file's path.
-	zipPaths []string // Zip file path.
-	outPath  string   // Output file path.
-	exeBytes *[]byte  // Bytes of the executable file.
-	zipBytes *[]byte  // Bytes of the zip file.
-	sigBytes *[]byte  // Bytes of the signature.
+	exePath      string            // Executable file's path.
+	zipPaths     []zipTarget       // Zip targets.
+	zipFs        []FileSystem      // File systems to add to zip.
+	excludes     []string          // Glob patterns to exclude.
+	method       uint16            // Compression method.
+	symlink      SymlinkMode       // Symlink handling mode.
+	metadata     map[string]string // Metadata of the payload.
+	outPath      string            // Output file path.
+	maxSize      int64             // Max byte size of the payload.
+	gzipPatterns []string          // Glob patterns of the files to precompress.
+	fpPatterns   []string          // Glob patterns of the files to fingerprint.
+	report       *BuildReport      // Build report.
+	isPack       bool              // Build an asset pack without exe?
+	configPack   bool              // Build an asset pack by Build()? (Set by the config)
+	exeBytes     *[]byte           // Bytes of the executable file.
+	zipBytes     *[]byte           // Bytes of the zip file.
+	sigBytes     *[]byte           // Bytes of the signature.
+}
+
+// Target path to add to zip.
+type zipTarget struct {
+	path     string   // Source path.
+	destPath string   // Destination path in zip.
+	excludes []string // Glob patterns to exclude.
 }
 
 // Initialize new zgok builder.
 func NewZgokBuilder() Builder {
-	b := &zgokBuilder{}
+	b := &zgokBuilder{
+		method:   zip.Deflate,
+		metadata: make(map[string]string),
+	}
 	return b
 }
 
+// Initialize new zgok builder from the build configuration.
+func NewZgokBuilderFromConfig(config *BuildConfig) (Builder, error) {
+	b := NewZgokBuilder()
+	err := b.ApplyConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 // Set executable file path.
 func (b *zgokBuilder) SetExePath(exePath string) error {
 	_, err := os.Stat(exePath)
@@ -42,21 +89,145 @@ func (b *zgokBuilder) SetExePath(exePath string) error {
 
 // Add paths to add to zip.
 func (b *zgokBuilder) AddZipPath(zipPath string) error {
+	return b.AddZipPathAs(zipPath, "")
+}
+
+// Add paths to add to zip as the destination path.
+func (b *zgokBuilder) AddZipPathAs(zipPath, destPath string, excludes ...string) error {
 	_, err := os.Stat(zipPath)
 	if err != nil {
 		return err
 	}
-	b.zipPaths = append(b.zipPaths, zipPath)
+	if destPath != "" {
+		if _, err := cleanDestPath(destPath); err != nil {
+			return err
+		}
+	}
+	if err := validatePatterns(excludes); err != nil {
+		return err
+	}
+	target := zipTarget{path: zipPath, destPath: destPath, excludes: excludes}
+	b.zipPaths = append(b.zipPaths, target)
+	return nil
+}
+
+// Add all the entries in the file system to zip.
+func (b *zgokBuilder) AddFileSystem(fs FileSystem) {
+	b.zipFs = append(b.zipFs, fs)
+}
+
+// Add glob patterns of the paths to exclude.
+func (b *zgokBuilder) AddExcludes(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	b.excludes = append(b.excludes, patterns...)
+	return nil
+}
+
+// Set compression method. (zip.Deflate or zip.Store)
+func (b *zgokBuilder) SetCompression(method uint16) error {
+	if method != zip.Deflate && method != zip.Store {
+		return fmt.Errorf("unsupported compression method [%d]", method)
+	}
+	b.method = method
 	return nil
 }
 
+// Set symlink handling mode.
+func (b *zgokBuilder) SetSymlinkMode(mode SymlinkMode) {
+	b.symlink = mode
+}
+
+// Set metadata stored in the payload.
+func (b *zgokBuilder) SetMetadata(key, value string) {
+	b.metadata[key] = value
+}
+
 // Set output path.
 func (b *zgokBuilder) SetOutPath(outPath string) {
 	b.outPath = outPath
 }
 
+// Set max byte size of the payload. (0 means unlimited)
+func (b *zgokBuilder) SetMaxSize(maxSize int64) {
+	b.maxSize = maxSize
+}
+
+// Set glob patterns of the files to add the gzip precompressed siblings.
+func (b *zgokBuilder) SetPrecompress(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	b.gzipPatterns = patterns
+	return nil
+}
+
+// Set glob patterns of the files to fingerprint in the asset manifest.
+func (b *zgokBuilder) SetFingerprint(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	b.fpPatterns = patterns
+	return nil
+}
+
+// Get the report of the last build.
+func (b *zgokBuilder) Report() *BuildReport {
+	return b.report
+}
+
+// Apply the build configuration.
+func (b *zgokBuilder) ApplyConfig(config *BuildConfig) error {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+	if config.Exe != "" {
+		err = b.SetExePath(config.Exe)
+		if err != nil {
+			return &ConfigError{Key: "exe", Err: err}
+		}
+	}
+	if config.Output != "" {
+		b.SetOutPath(config.Output)
+	}
+	for i, input := range config.Inputs {
+		err = b.AddZipPathAs(input.Path, input.Dest, input.Excludes...)
+		if err != nil {
+			return &ConfigError{Key: fmt.Sprintf("inputs[%d].path", i), Err: err}
+		}
+	}
+	b.AddExcludes(config.Excludes...)
+	method, _ := ParseCompression(config.Compression)
+	b.SetCompression(method)
+	symlinkMode, _ := ParseSymlinkMode(config.Symlinks)
+	b.SetSymlinkMode(symlinkMode)
+	for key, value := range config.Metadata {
+		b.SetMetadata(key, value)
+	}
+	b.SetMaxSize(config.MaxSize)
+	b.SetPrecompress(config.Precompress...)
+	b.SetFingerprint(config.Fingerprint...)
+	b.configPack = config.Pack
+	return nil
+}
+
 // Build zgok file.
+// Asset pack file is built if the applied config sets pack.
 func (b *zgokBuilder) Build() error {
+	b.isPack = b.configPack
+	return b.build()
+}
+
+// Build asset pack file. (zip and signature without exe)
+func (b *zgokBuilder) BuildPack() error {
+	b.isPack = true
+	return b.build()
+}
+
+// Build zgok file or asset pack file.
+func (b *zgokBuilder) build() error {
 	// Set exe file bytes.
 	err := b.setExeBytes()
 	if err != nil {
@@ -72,6 +243,11 @@ func (b *zgokBuilder) Build() error {
 	if err != nil {
 		return err
 	}
+	// Set report and check the payload size.
+	err = b.setReport()
+	if err != nil {
+		return err
+	}
 	// Create out file.
 	err = b.createOutFile()
 	if err != nil {
@@ -82,10 +258,22 @@ func (b *zgokBuilder) Build() error {
 
 // Set exe file bytes.
 func (b *zgokBuilder) setExeBytes() error {
+	// Asset pack has no exe.
+	if b.isPack {
+		exeBytes := []byte{}
+		b.exeBytes = &exeBytes
+		return nil
+	}
 	exeBytes, err := ioutil.ReadFile(b.exePath)
 	if err != nil {
 		return err
 	}
+	// Remove the payload of the zgok executable file.
+	exeSize := int64(len(exeBytes))
+	signature, err := restoreSignatureAt(bytes.NewReader(exeBytes), exeSize)
+	if err == nil {
+		exeBytes = exeBytes[:signature.ExeSize()]
+	}
 	b.exeBytes = &exeBytes
 	return nil
 }
@@ -93,15 +281,37 @@ func (b *zgokBuilder) setExeBytes() error {
 // Set zip file bytes.
 func (b *zgokBuilder) setZipBytes() error {
 	// Check if zip paths are empty.
-	if len(b.zipPaths) == 0 {
+	if len(b.zipPaths) == 0 && len(b.zipFs) == 0 {
 		return fmt.Errorf("zip paths not set")
 	}
 	var err error
 	// Create new zipper.
 	zipper := NewZipper()
+	zipper.SetMethod(b.method)
+	zipper.SetSymlinkMode(b.symlink)
+	zipper.SetMetadata(b.buildMetadata())
+	err = zipper.AddExcludes(b.excludes...)
+	if err != nil {
+		return err
+	}
+	err = zipper.SetPrecompress(b.gzipPatterns...)
+	if err != nil {
+		return err
+	}
+	err = zipper.SetFingerprint(b.fpPatterns...)
+	if err != nil {
+		return err
+	}
 	// Add targets to zip.
-	for _, zipPath := range b.zipPaths {
-		err = zipper.Add(zipPath)
+	for _, fs := range b.zipFs {
+		err = zipper.AddFileSystem(fs)
+		if err != nil {
+			zipper.Close()
+			return err
+		}
+	}
+	for _, target := range b.zipPaths {
+		err = zipper.AddAs(target.path, target.destPath, target.excludes...)
 		if err != nil {
 			zipper.Close()
 			return err
@@ -121,10 +331,25 @@ func (b *zgokBuilder) setZipBytes() error {
 	return nil
 }
 
+// Get the metadata with the build time.
+// The build time is taken from "SOURCE_DATE_EPOCH" for reproducible builds.
+func (b *zgokBuilder) buildMetadata() map[string]string {
+	buildTime := time.Now()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		buildTime = time.Unix(epoch, 0)
+	}
+	metadata := make(map[string]string)
+	for key, value := range b.metadata {
+		metadata[key] = value
+	}
+	metadata[METADATA_BUILD_TIME] = buildTime.UTC().Format(time.RFC3339)
+	return metadata
+}
+
 // Set signature bytes.
 func (b *zgokBuilder) setSignatureBytes() error {
 	// Check if exeBytes and zipBytes are set.
-	if len(*b.exeBytes) <= 0 {
+	if len(*b.exeBytes) <= 0 && !b.isPack {
 		return fmt.Errorf("exe bytes not set")
 	}
 	if len(*b.zipBytes) <= 0 {
@@ -146,10 +371,24 @@ func (b *zgokBuilder) setSignatureBytes() error {
 	return nil
 }
 
+// Set report and check the payload size.
+func (b *zgokBuilder) setReport() error {
+	report, err := NewBuildReport(int64(len(*b.exeBytes)), *b.zipBytes)
+	if err != nil {
+		return err
+	}
+	b.report = report
+	if 0 < b.maxSize && b.maxSize < report.ZipSize {
+		return fmt.Errorf("payload size [%d] exceeds max size [%d]",
+			report.ZipSize, b.maxSize)
+	}
+	return nil
+}
+
 // Create out file.
 func (b *zgokBuilder) createOutFile() error {
 	// Create out file.
-	file, err := os.OpenFile(b.outPath, os.O_RDWR|os.O_CREATE, 0755)
+	file, err := os.OpenFile(b.outPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
 	if err != nil {
 		return err
 	}
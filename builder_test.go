@@ -1,6 +1,8 @@
 package zgok
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	fpath "path/filepath"
@@ -26,8 +28,8 @@ func TestMain(m *testing.M) {
 }
 
 func setup() {
-	// Erase unused *.out files.
-	paths, _ := fpath.Glob("*.out")
+	// Erase unused *.out and *.out.zgok files.
+	paths, _ := fpath.Glob("*.out*")
 	for _, path := range paths {
 		os.Remove(path)
 	}
@@ -40,8 +42,8 @@ func setup() {
 }
 
 func teardown() {
-	// Erase unused *.out files.
-	paths, _ := fpath.Glob("*.out")
+	// Erase unused *.out and *.out.zgok files.
+	paths, _ := fpath.Glob("*.out*")
 	for _, path := range paths {
 		os.Remove(path)
 	}
@@ -160,3 +162,85 @@ func TestBuilderBuildWithoutZipPath(t *testing.T) {
 		t.Errorf("Expected error on build without zip path.")
 	}
 }
+
+func TestRestoreWithLimits(t *testing.T) {
+	// Build zgok file.
+	outPath := "builder_limits_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	// Restore with limits.
+	_, err = RestoreFileSystem(outPath, WithLimits(Limits{MaxFileSize: 2}))
+	if _, ok := err.(*LimitError); !ok {
+		t.Errorf("Expected limit error got [%v].", err)
+	}
+	_, err = RestoreFileSystem(outPath, WithLimits(Limits{MaxFileSize: 3}))
+	if err != nil {
+		t.Errorf("RestoreFileSystem():error=[%v]", err)
+	}
+}
+
+func TestRestoreFromBytes(t *testing.T) {
+	// Build zgok file.
+	outPath := "builder_bytes_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	data, _ := ioutil.ReadFile(outPath)
+	// Restore from bytes.
+	zfs, err := RestoreFromBytes(data)
+	if err != nil {
+		t.Fatalf("RestoreFromBytes():error=[%v]", err)
+	}
+	fooStr, _ := zfs.ReadFileString("testdata/foo")
+	if fooStr != "foo" {
+		t.Errorf(`expected "foo" got "%v"`, fooStr)
+	}
+	// Restore from reader at.
+	zfs, err = RestoreFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("RestoreFromReaderAt():error=[%v]", err)
+	}
+	if zfs.Signature().TotalSize() != int64(len(data)) {
+		t.Errorf("TotalSize():expected [%v] got [%v].",
+			len(data), zfs.Signature().TotalSize())
+	}
+	// Fail on broken data.
+	if _, err = RestoreFromBytes(data[1:]); err == nil {
+		t.Errorf("Expected error on truncated data.")
+	}
+	if _, err = RestoreFromBytes(data[:10]); err == nil {
+		t.Errorf("Expected error on short data.")
+	}
+}
+
+func TestBuilderBuildTime(t *testing.T) {
+	os.Setenv("SOURCE_DATE_EPOCH", "1600000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	outPath := "builder_build_time_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath(DummyExePath)
+	builder.AddZipPath("testdata/foo")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	if zfs.BuildTime().Unix() != 1600000000 {
+		t.Errorf("BuildTime():unexpected [%v]", zfs.BuildTime())
+	}
+}
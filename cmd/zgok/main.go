@@ -4,7 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"github.com/srtkkou/zgok"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -51,9 +55,13 @@ func main() {
 	// Switch by command.
 	switch args[0] {
 	case "build":
-		runBuildCommand(args[1:])
+		runBuildCommand(args[1:], false)
+	case "pack":
+		runBuildCommand(args[1:], true)
 	case "show":
 		runShowCommand(args[1:])
+	case "extract":
+		runExtractCommand(args[1:])
 	default:
 		usage()
 		os.Exit(ERROR_CODE)
@@ -66,7 +74,9 @@ func usage() {
 	fmt.Println()
 	fmt.Println("commands:")
 	fmt.Println("  build     : Build zgok executable file.")
+	fmt.Println("  pack      : Build zgok asset pack file without executable.")
 	fmt.Println("  show      : Show information in zgok executable file.")
+	fmt.Println("  extract   : Extract files in zgok executable file.")
 	fmt.Println()
 	fmt.Println("global flags:")
 	fmt.Println("  -h        : Print this help message.")
@@ -76,13 +86,30 @@ func usage() {
 	fmt.Println("  -e string : [REQUIRED] Executable file's path.")
 	fmt.Println("  -z string : [REQUIRED] Target paths to add to zip.")
 	fmt.Println("  -o string : Output file's path.")
+	fmt.Println("  -c string : Build config file's path. (*.json or *.toml)")
+	fmt.Println("              Replaces [-e] and [-z].")
+	fmt.Println("  -report string     : Print build report. (text or json)")
+	fmt.Println("  -report-out string : Report output file's path.")
+	fmt.Println("  -max-size string   : Max payload size. (ex. 512K, 10M, 1G)")
+	fmt.Println("  -symlinks string   : Symlink mode. (follow, preserve or reject)")
+	fmt.Println("  -precompress string: Files to add gzip siblings. (ex. \"*.js\")")
+	fmt.Println("  -fingerprint string: Files to fingerprint in the asset manifest.")
+	fmt.Println()
+	fmt.Println("pack command flags:")
+	fmt.Println("  Same as build command flags except [-e].")
 	fmt.Println()
 	fmt.Println("show command flags:")
 	fmt.Println("  -f        : [REQUIRED] Zgok file's path.")
+	fmt.Println()
+	fmt.Println("extract command flags: [flags] [paths or glob patterns...]")
+	fmt.Println("  -f string : [REQUIRED] Zgok file's path.")
+	fmt.Println("  -o string : Output directory's path. (default \".\")")
+	fmt.Println("  -policy string : Policy for existing files. (overwrite, skip or fail)")
 }
 
 // Run build command.
-func runBuildCommand(args []string) {
+// Build an asset pack if isPack is true.
+func runBuildCommand(args []string, isPack bool) {
 	// Check argument length.
 	if len(args) == 0 {
 		usage()
@@ -90,40 +117,191 @@ func runBuildCommand(args []string) {
 	}
 	// Parse flags
 	var (
-		exePath  string
-		zipPaths strSlice
-		outPath  string
+		exePath    string
+		zipPaths   strSlice
+		outPath    string
+		configPath string
+		reportFmt  string
+		reportOut  string
+		maxSizeStr string
+		symlinks   string
+		gzipGlobs  strSlice
+		fpGlobs    strSlice
 	)
 	fs := flag.NewFlagSet("build", flag.ExitOnError)
 	fs.StringVar(&exePath, "e", "", "Executable file's path.")
 	fs.Var(&zipPaths, "z", "ZIP target paths.")
-	fs.StringVar(&outPath, "o", "out", "Output file's path.")
+	fs.StringVar(&outPath, "o", "", "Output file's path.")
+	fs.StringVar(&configPath, "c", "", "Build config file's path.")
+	fs.StringVar(&reportFmt, "report", "", "Build report format.")
+	fs.StringVar(&reportOut, "report-out", "", "Report output file's path.")
+	fs.StringVar(&maxSizeStr, "max-size", "", "Max payload size.")
+	fs.StringVar(&symlinks, "symlinks", "", "Symlink mode.")
+	fs.Var(&gzipGlobs, "precompress", "Glob patterns of the files to precompress.")
+	fs.Var(&fpGlobs, "fingerprint", "Glob patterns of the files to fingerprint.")
 	fs.Parse(args)
-	// Validate arguments.
-	if exePath == "" || len(zipPaths) == 0 || outPath == "" {
+	if reportFmt != "" && reportFmt != "text" && reportFmt != "json" {
 		usage()
 		os.Exit(ERROR_CODE)
 	}
 	// Initialize builder.
-	builder := zgok.NewZgokBuilder()
+	var builder zgok.Builder
+	if configPath != "" {
+		builder = newConfigBuilder(configPath, &outPath, &isPack)
+	} else {
+		builder = newFlagBuilder(exePath, zipPaths, &outPath, isPack)
+	}
 	builder.SetOutPath(outPath)
-	err := builder.SetExePath(exePath)
+	if symlinks != "" {
+		symlinkMode, err := zgok.ParseSymlinkMode(symlinks)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(ERROR_CODE)
+		}
+		builder.SetSymlinkMode(symlinkMode)
+	}
+	if len(gzipGlobs) > 0 {
+		err := builder.SetPrecompress(gzipGlobs...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(ERROR_CODE)
+		}
+	}
+	if len(fpGlobs) > 0 {
+		err := builder.SetFingerprint(fpGlobs...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(ERROR_CODE)
+		}
+	}
+	if maxSizeStr != "" {
+		maxSize, err := parseSize(maxSizeStr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(ERROR_CODE)
+		}
+		builder.SetMaxSize(maxSize)
+	}
+	// Build zgok file.
+	var buildErr error
+	if isPack {
+		buildErr = builder.BuildPack()
+	} else {
+		buildErr = builder.Build()
+	}
+	// Write report even if the payload exceeds the max size.
+	if reportFmt != "" && builder.Report() != nil {
+		err := writeReport(builder.Report(), reportFmt, reportOut)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(ERROR_CODE)
+		}
+	}
+	if buildErr != nil {
+		fmt.Fprintln(os.Stderr, buildErr.Error())
+		os.Exit(ERROR_CODE)
+	}
+	// Keep stdout only for the report written to it.
+	if reportFmt != "" && reportOut == "" {
+		fmt.Fprintf(os.Stderr, "Exported %s\n", outPath)
+		return
+	}
+	fmt.Printf("Exported %s\n", outPath)
+}
+
+// Write build report.
+func writeReport(report *zgok.BuildReport, format, outPath string) error {
+	var content []byte
+	if format == "json" {
+		jsonBytes, err := report.JSON()
+		if err != nil {
+			return err
+		}
+		content = append(jsonBytes, '\n')
+	} else {
+		content = []byte(report.Text())
+	}
+	if outPath == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(outPath, content, 0644)
+}
+
+// Parse byte size with an optional unit. (K, M, G)
+func parseSize(s string) (int64, error) {
+	units := map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+	str := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	multiplier := int64(1)
+	if len(str) > 0 {
+		if unit, exists := units[str[len(str)-1:]]; exists {
+			multiplier = unit
+			str = str[:len(str)-1]
+		}
+	}
+	size, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size [%s]", s)
+	}
+	return size * multiplier, nil
+}
+
+// Initialize builder from the config file.
+func newConfigBuilder(configPath string, outPath *string, isPack *bool) zgok.Builder {
+	config, err := zgok.LoadBuildConfig(configPath)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ERROR_CODE)
+	}
+	builder, err := zgok.NewZgokBuilderFromConfig(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ERROR_CODE)
+	}
+	// Flag overrides the output path in config.
+	if *outPath == "" {
+		*outPath = config.Output
+	}
+	*isPack = *isPack || config.Pack
+	if *outPath == "" {
+		*outPath = defaultOutPath(*isPack)
+	}
+	return builder
+}
+
+// Initialize builder from the flags.
+func newFlagBuilder(exePath string, zipPaths strSlice, outPath *string, isPack bool) zgok.Builder {
+	if *outPath == "" {
+		*outPath = defaultOutPath(isPack)
+	}
+	// Validate arguments.
+	if (exePath == "" && !isPack) || len(zipPaths) == 0 {
+		usage()
+		os.Exit(ERROR_CODE)
+	}
+	builder := zgok.NewZgokBuilder()
+	if !isPack {
+		err := builder.SetExePath(exePath)
+		if err != nil {
+			panic(err)
+		}
 	}
 	// Add zip paths.
 	for _, zipPath := range zipPaths {
-		err = builder.AddZipPath(zipPath)
+		err := builder.AddZipPath(zipPath)
 		if err != nil {
 			panic(err)
 		}
 	}
-	// Build zgok file.
-	err = builder.Build()
-	if err != nil {
-		panic(err)
+	return builder
+}
+
+// Get default output path.
+func defaultOutPath(isPack bool) string {
+	if isPack {
+		return "out" + zgok.PACK_EXTENSION
 	}
-	fmt.Printf("Exported %s\n", outPath)
+	return "out"
 }
 
 // Run show command.
@@ -154,6 +332,20 @@ func runShowCommand(args []string) {
 	// Show version.
 	fmt.Println("Signature:")
 	fmt.Println("  " + zfs.String())
+	// Show metadata.
+	metadata := zfs.Metadata()
+	if len(metadata) > 0 {
+		keys := make([]string, 0, len(metadata))
+		for key := range metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println()
+		fmt.Println("Metadata:")
+		for _, key := range keys {
+			fmt.Printf("  %s: %s\n", key, metadata[key])
+		}
+	}
 	// Show paths.
 	fmt.Println()
 	fmt.Println("Paths:")
@@ -161,3 +353,51 @@ func runShowCommand(args []string) {
 		fmt.Println("  " + path)
 	}
 }
+
+// Run extract command.
+func runExtractCommand(args []string) {
+	// Check argument length.
+	if len(args) == 0 {
+		usage()
+		os.Exit(ERROR_CODE)
+	}
+	// Parse flags.
+	var (
+		filePath  string
+		outDir    string
+		policyStr string
+	)
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	fs.StringVar(&filePath, "f", "", "Zgok file's path.")
+	fs.StringVar(&outDir, "o", ".", "Output directory's path.")
+	fs.StringVar(&policyStr, "policy", "overwrite", "Policy for existing files.")
+	fs.Parse(args)
+	// Check file path.
+	if filePath == "" {
+		usage()
+		os.Exit(ERROR_CODE)
+	}
+	policy, err := zgok.ParseExtractPolicy(policyStr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ERROR_CODE)
+	}
+	filter, err := zgok.GlobFilter(fs.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ERROR_CODE)
+	}
+	// Restore zgok file system.
+	zfs, err := zgok.RestoreFileSystem(filePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ERROR_CODE)
+	}
+	// Extract files.
+	err = zfs.ExtractTo(outDir, filter, policy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(ERROR_CODE)
+	}
+	fmt.Printf("Extracted to %s\n", outDir)
+}
@@ -0,0 +1,228 @@
+package zgok
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	COMPRESSION_DEFLATE = "deflate" // Compress files with deflate.
+	COMPRESSION_STORE   = "store"   // Store files without compression.
+)
+
+// Build configuration.
+type BuildConfig struct {
+	Exe         string            `json:"exe"`         // Executable file's path.
+	Pack        bool              `json:"pack"`        // Build an asset pack without exe?
+	Output      string            `json:"output"`      // Output file's path.
+	Inputs      []BuildInput      `json:"inputs"`      // Paths to add to zip.
+	Excludes    []string          `json:"excludes"`    // Glob patterns to exclude.
+	Compression string            `json:"compression"` // Compression method.
+	Symlinks    string            `json:"symlinks"`    // Symlink handling mode.
+	Metadata    map[string]string `json:"metadata"`    // Metadata of the payload.
+	MaxSize     int64             `json:"max_size"`    // Max byte size of the payload.
+	Precompress []string          `json:"precompress"` // Glob patterns of the files to precompress.
+	Fingerprint []string          `json:"fingerprint"` // Glob patterns of the files to fingerprint.
+}
+
+// Build input of the build configuration.
+type BuildInput struct {
+	Path     string   `json:"path"`     // Path of the file or directory.
+	Dest     string   `json:"dest"`     // Destination path in zip.
+	Excludes []string `json:"excludes"` // Glob patterns to exclude.
+}
+
+// Configuration error.
+type ConfigError struct {
+	Key string // Offending key. (ex. "inputs[0].path")
+	Err error  // Cause of the error.
+}
+
+// Get error message.
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("config: %v", e.Err)
+	}
+	return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+}
+
+// Load build configuration from the file.
+// Files with ".toml" extension are read as TOML, others as JSON.
+// Relative paths are resolved from the directory of the config file.
+func LoadBuildConfig(path string) (*BuildConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config *BuildConfig
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		config, err = ParseBuildConfigTOML(data)
+	} else {
+		config, err = ParseBuildConfigJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	config.resolvePaths(filepath.Dir(path))
+	return config, nil
+}
+
+// Parse build configuration in JSON.
+func ParseBuildConfigJSON(data []byte) (*BuildConfig, error) {
+	config := &BuildConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(config)
+	if err != nil {
+		return nil, toConfigError(err)
+	}
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Parse build configuration in TOML.
+func ParseBuildConfigTOML(data []byte) (*BuildConfig, error) {
+	table, err := parseTOML(data)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	jsonBytes, err := json.Marshal(table)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	return ParseBuildConfigJSON(jsonBytes)
+}
+
+// Validate the build configuration.
+func (c *BuildConfig) Validate() error {
+	if c.Exe == "" && !c.Pack {
+		return &ConfigError{Key: "exe", Err: fmt.Errorf("required")}
+	}
+	if len(c.Inputs) == 0 {
+		return &ConfigError{Key: "inputs", Err: fmt.Errorf("required")}
+	}
+	for i, input := range c.Inputs {
+		key := fmt.Sprintf("inputs[%d]", i)
+		if input.Path == "" {
+			return &ConfigError{Key: key + ".path", Err: fmt.Errorf("required")}
+		}
+		if input.Dest != "" {
+			if _, err := cleanDestPath(input.Dest); err != nil {
+				return &ConfigError{Key: key + ".dest", Err: err}
+			}
+		} else if _, err := cleanDestPath(input.Path); err != nil {
+			return &ConfigError{Key: key + ".dest",
+				Err: fmt.Errorf("required for path [%s]", input.Path)}
+		}
+		err := validatePatterns(input.Excludes)
+		if err != nil {
+			return &ConfigError{Key: key + ".excludes", Err: err}
+		}
+	}
+	if err := validatePatterns(c.Excludes); err != nil {
+		return &ConfigError{Key: "excludes", Err: err}
+	}
+	if _, err := ParseCompression(c.Compression); err != nil {
+		return &ConfigError{Key: "compression", Err: err}
+	}
+	if _, err := ParseSymlinkMode(c.Symlinks); err != nil {
+		return &ConfigError{Key: "symlinks", Err: err}
+	}
+	if err := validatePatterns(c.Precompress); err != nil {
+		return &ConfigError{Key: "precompress", Err: err}
+	}
+	if err := validatePatterns(c.Fingerprint); err != nil {
+		return &ConfigError{Key: "fingerprint", Err: err}
+	}
+	if c.MaxSize < 0 {
+		return &ConfigError{Key: "max_size", Err: fmt.Errorf("must not be negative")}
+	}
+	return nil
+}
+
+// Parse compression method name.
+// Empty name means "deflate".
+func ParseCompression(name string) (uint16, error) {
+	switch strings.ToLower(name) {
+	case "", COMPRESSION_DEFLATE:
+		return zip.Deflate, nil
+	case COMPRESSION_STORE:
+		return zip.Store, nil
+	}
+	return 0, fmt.Errorf("unknown compression [%s]", name)
+}
+
+// Resolve relative paths from the base directory.
+func (c *BuildConfig) resolvePaths(baseDir string) {
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(baseDir, p)
+	}
+	c.Exe = resolve(c.Exe)
+	c.Output = resolve(c.Output)
+	for i := range c.Inputs {
+		input := &c.Inputs[i]
+		// Keep the given path as the destination path.
+		if input.Dest == "" {
+			input.Dest = input.Path
+		}
+		input.Path = resolve(input.Path)
+	}
+}
+
+// Validate glob patterns.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern [%s]: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Convert JSON decode error to config error.
+func toConfigError(err error) error {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		return &ConfigError{Key: configKey(e.Field),
+			Err: fmt.Errorf("expected %s got %s", e.Type, e.Value)}
+	case *json.SyntaxError:
+		return &ConfigError{Err: fmt.Errorf("%v (offset %d)", e, e.Offset)}
+	}
+	// Unknown fields are reported as `json: unknown field "key"`.
+	msg := err.Error()
+	if strings.HasPrefix(msg, `json: unknown field "`) {
+		key := strings.TrimSuffix(strings.TrimPrefix(msg, `json: unknown field "`), `"`)
+		return &ConfigError{Key: key, Err: fmt.Errorf("unknown key")}
+	}
+	return &ConfigError{Err: err}
+}
+
+// Convert the field path of the JSON decoder to the config key.
+// (ex. "inputs.0.path" to "inputs[0].path")
+func configKey(field string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,211 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"testing"
+)
+
+func TestParseBuildConfigJSON(t *testing.T) {
+	data := []byte(`{
+		"exe": "testdata/executable",
+		"output": "config_test.out",
+		"inputs": [
+			{"path": "testdata/foo", "dest": "assets/foo"},
+			{"path": "testdata/dir", "excludes": ["baz"]}
+		],
+		"compression": "store",
+		"metadata": {"version": "1.2.3"}
+	}`)
+	config, err := ParseBuildConfigJSON(data)
+	if err != nil {
+		t.Fatalf("ParseBuildConfigJSON():error=[%v]", err)
+	}
+	if config.Exe != "testdata/executable" {
+		t.Errorf("Exe:expected [%v] got [%v].", "testdata/executable", config.Exe)
+	}
+	if len(config.Inputs) != 2 || config.Inputs[0].Dest != "assets/foo" {
+		t.Errorf("Inputs:unexpected [%v].", config.Inputs)
+	}
+	if config.Metadata["version"] != "1.2.3" {
+		t.Errorf("Metadata:unexpected [%v].", config.Metadata)
+	}
+}
+
+func TestParseBuildConfigTOML(t *testing.T) {
+	data := []byte(`# zgok build config
+exe = "testdata/executable"
+output = 'config_test.out' # output
+excludes = [
+  "*.tmp", # temporary files
+  "#*",
+]
+
+[[inputs]]
+path = "testdata/foo"
+dest = "assets/foo"
+
+[[inputs]]
+path = "testdata/dir"
+excludes = ["baz"]
+
+[metadata]
+version = "1.2.3"
+"build.host" = "ci"
+`)
+	config, err := ParseBuildConfigTOML(data)
+	if err != nil {
+		t.Fatalf("ParseBuildConfigTOML():error=[%v]", err)
+	}
+	if config.Output != "config_test.out" {
+		t.Errorf("Output:expected [%v] got [%v].", "config_test.out", config.Output)
+	}
+	if len(config.Excludes) != 2 || config.Excludes[1] != "#*" {
+		t.Errorf("Excludes:unexpected [%v].", config.Excludes)
+	}
+	if len(config.Inputs) != 2 || config.Inputs[1].Excludes[0] != "baz" {
+		t.Errorf("Inputs:unexpected [%v].", config.Inputs)
+	}
+	if config.Metadata["build.host"] != "ci" {
+		t.Errorf("Metadata:unexpected [%v].", config.Metadata)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		msg  string
+	}{
+		{"excludes = [\n  \"a\",\n", "line 1: excludes: unterminated array"},
+		{"excludes = [\"a\"]]", "line 1: excludes: unexpected []]"},
+		{"metadata = {version = \"1\"}", "line 1: metadata: inline tables are unsupported"},
+		{"exe = \"a\"\nexe = \"b\"", "line 2: duplicate key [exe]"},
+	}
+	for _, test := range tests {
+		_, err := parseTOML([]byte(test.data))
+		if err == nil || err.Error() != test.msg {
+			t.Errorf("[%s]:expected [%v] got [%v].", test.data, test.msg, err)
+		}
+	}
+}
+
+func TestBuildConfigErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		key  string
+	}{
+		{`{"inputs": [{"path": "testdata/foo"}]}`, "exe"},
+		{`{"exe": "a"}`, "inputs"},
+		{`{"exe": "a", "inputs": [{"dest": "b"}]}`, "inputs[0].path"},
+		{`{"exe": "a", "inputs": [{"path": "b", "dest": "../c"}]}`, "inputs[0].dest"},
+		{`{"exe": "a", "inputs": [{"path": "b", "excludes": ["["]}]}`, "inputs[0].excludes"},
+		{`{"exe": "a", "inputs": [{"path": "b"}], "compression": "lzma"}`, "compression"},
+		{`{"exe": "a", "inputs": [{"path": "b"}], "precompress": ["["]}`, "precompress"},
+		{`{"exe": "a", "inputs": [{"path": "b"}], "fingerprint": ["["]}`, "fingerprint"},
+		{`{"exe": "a", "inputs": [{"path": "b"}], "unknown": 1}`, "unknown"},
+		{`{"exe": 1, "inputs": [{"path": "b"}]}`, "exe"},
+		{`{"exe": "a", "inputs": [{"path": "b"}, {"path": 1}]}`, "inputs[1].path"},
+	}
+	for _, test := range tests {
+		_, err := ParseBuildConfigJSON([]byte(test.data))
+		configErr, ok := err.(*ConfigError)
+		if !ok {
+			t.Errorf("[%s]:expected config error got [%v].", test.data, err)
+			continue
+		}
+		if configErr.Key != test.key {
+			t.Errorf("[%s]:expected key [%v] got [%v].", test.data, test.key, configErr.Key)
+		}
+	}
+}
+
+func TestBuildWithConfig(t *testing.T) {
+	// Write config file.
+	dir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	configPath := fpath.Join(dir, "zgok.json")
+	outPath := fpath.Join(cwd, "config_test.out")
+	data := []byte(`{
+		"exe": "` + fpath.ToSlash(fpath.Join(cwd, "testdata/executable")) + `",
+		"output": "` + fpath.ToSlash(outPath) + `",
+		"inputs": [
+			{"path": "` + fpath.ToSlash(fpath.Join(cwd, "testdata/foo")) + `", "dest": "assets/foo"},
+			{"path": "` + fpath.ToSlash(fpath.Join(cwd, "testdata/dir")) + `", "dest": "assets/dir"}
+		],
+		"excludes": ["baz"],
+		"compression": "store",
+		"metadata": {"version": "1.2.3"}
+	}`)
+	err = ioutil.WriteFile(configPath, data, 0644)
+	if err != nil {
+		t.Fatalf("WriteFile():error=[%v]", err)
+	}
+	// Build zgok file.
+	config, err := LoadBuildConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadBuildConfig():error=[%v]", err)
+	}
+	builder, err := NewZgokBuilderFromConfig(config)
+	if err != nil {
+		t.Fatalf("NewZgokBuilderFromConfig():error=[%v]", err)
+	}
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	// Load zgok filesystem.
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	expectedPaths := []string{"assets/dir/bar", "assets/foo"}
+	paths := zfs.Paths()
+	if len(paths) != len(expectedPaths) {
+		t.Fatalf("Paths():expected [%v] got [%v].", expectedPaths, paths)
+	}
+	for i, path := range paths {
+		if expectedPaths[i] != path {
+			t.Errorf("Paths():expected [%v] got [%v].", expectedPaths[i], path)
+		}
+	}
+	if zfs.Metadata()["version"] != "1.2.3" {
+		t.Errorf("Metadata():unexpected [%v].", zfs.Metadata())
+	}
+	fooStr, _ := zfs.ReadFileString("assets/foo")
+	if fooStr != "foo" {
+		t.Errorf(`expected "foo" got "%v"`, fooStr)
+	}
+}
+
+func TestBuildPackWithConfig(t *testing.T) {
+	config, err := ParseBuildConfigJSON([]byte(`{
+		"pack": true,
+		"output": "config_pack_test.out",
+		"inputs": [{"path": "testdata/foo"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseBuildConfigJSON():error=[%v]", err)
+	}
+	builder, err := NewZgokBuilderFromConfig(config)
+	if err != nil {
+		t.Fatalf("NewZgokBuilderFromConfig():error=[%v]", err)
+	}
+	// Build() honours the pack of the config.
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestorePack("config_pack_test.out")
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	fooStr, _ := zfs.ReadFileString("testdata/foo")
+	if fooStr != "foo" {
+		t.Errorf(`expected "foo" got "%v"`, fooStr)
+	}
+}
@@ -0,0 +1,84 @@
+package zgok
+
+import (
+	"encoding/binary"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Content types by the extensions.
+// Embedded not to depend on the mime types of the host.
+var contentTypes = map[string]string{
+	".apng":        "image/apng",
+	".avif":        "image/avif",
+	".css":         "text/css; charset=utf-8",
+	".csv":         "text/csv; charset=utf-8",
+	".gif":         "image/gif",
+	".gz":          "application/gzip",
+	".htm":         "text/html; charset=utf-8",
+	".html":        "text/html; charset=utf-8",
+	".ico":         "image/x-icon",
+	".jpeg":        "image/jpeg",
+	".jpg":         "image/jpeg",
+	".js":          "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".md":          "text/markdown; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".mp3":         "audio/mpeg",
+	".mp4":         "video/mp4",
+	".oga":         "audio/ogg",
+	".ogg":         "audio/ogg",
+	".ogv":         "video/ogg",
+	".otf":         "font/otf",
+	".pdf":         "application/pdf",
+	".png":         "image/png",
+	".svg":         "image/svg+xml",
+	".ttf":         "font/ttf",
+	".txt":         "text/plain; charset=utf-8",
+	".wasm":        "application/wasm",
+	".wav":         "audio/wav",
+	".webm":        "video/webm",
+	".webmanifest": "application/manifest+json",
+	".webp":        "image/webp",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".xml":         "text/xml; charset=utf-8",
+	".zip":         "application/zip",
+}
+
+// Set the content types by the extensions overriding the stored types.
+// (ex. map[string]string{".js": "application/javascript"})
+func WithContentTypes(overrides map[string]string) ServerOption {
+	return func(s *fileServer) {
+		for ext, ctype := range overrides {
+			s.contentTypes[strings.ToLower(ext)] = ctype
+		}
+	}
+}
+
+// Detect the content type from the extension table or the content.
+func detectContentType(name string, content []byte) string {
+	if ctype, exists := contentTypes[strings.ToLower(path.Ext(name))]; exists {
+		return ctype
+	}
+	if len(content) > 512 {
+		content = content[:512]
+	}
+	return http.DetectContentType(content)
+}
+
+// Create the extra field holding the content type.
+func contentTypeExtra(ctype string) []byte {
+	extra := make([]byte, 4, 4+len(ctype))
+	binary.LittleEndian.PutUint16(extra[0:], CONTENT_TYPE_EXTRA_ID)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(ctype)))
+	return append(extra, ctype...)
+}
+
+// Get the content type in the extra field.
+// Returns empty if the extra field holds no content type.
+func parseContentTypeExtra(extra []byte) string {
+	return string(findExtra(extra, CONTENT_TYPE_EXTRA_ID))
+}
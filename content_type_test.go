@@ -0,0 +1,63 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fpath "path/filepath"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	ioutil.WriteFile(fpath.Join(srcDir, "app.wasm"), []byte("\x00asm"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "app.mjs"), []byte("export {};"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "page"), []byte("<!DOCTYPE html><html></html>"), 0644)
+	// Build zgok file.
+	outPath := "content_type_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "public")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	// Content types are recorded in the payload.
+	expected := map[string]string{
+		"/app.wasm": "application/wasm",
+		"/app.mjs":  "text/javascript; charset=utf-8",
+		"/page":     "text/html; charset=utf-8",
+	}
+	for urlPath, ctype := range expected {
+		file, _ := zfs.GetFile("public" + urlPath)
+		if zf := file.(*zgokFile); zf.ctype != ctype {
+			t.Errorf(`[%v] expected "%v" got "%v"`, urlPath, ctype, zf.ctype)
+		}
+	}
+	// Serve the stored types with the overrides.
+	expected["/app.mjs"] = "application/javascript"
+	ts := httptest.NewServer(zfs.FileServer("public",
+		WithContentTypes(map[string]string{".MJS": "application/javascript"})))
+	defer ts.Close()
+	for urlPath, ctype := range expected {
+		res, err := http.Get(ts.URL + urlPath)
+		if err != nil {
+			t.Fatalf("Get [%v] failed.", urlPath)
+		}
+		res.Body.Close()
+		if res.Header.Get("Content-Type") != ctype {
+			t.Errorf(`[%v] expected "%v" got "%v"`, urlPath, ctype, res.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package zgok
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default template of the directory listing.
+const DEFAULT_LISTING_TEMPLATE = `<!doctype html>
+<meta name="viewport" content="width=device-width">
+<pre>
+{{range .Entries}}<a href="{{.URL}}">{{.Name}}{{if .IsDir}}/{{end}}</a>
+{{end}}</pre>
+`
+
+// Directory listing.
+type Listing struct {
+	Path    string         `json:"path"`    // URL path of the directory.
+	Entries []ListingEntry `json:"entries"` // Entries in the directory.
+}
+
+// Entry of the directory listing.
+type ListingEntry struct {
+	Name    string    `json:"name"`           // Name of the entry.
+	URL     string    `json:"url"`            // Relative URL of the entry.
+	IsDir   bool      `json:"isDir"`          // Is the entry a directory?
+	Size    int64     `json:"size"`           // Byte size of the file.
+	ModTime time.Time `json:"modTime"`        // Modified time.
+	Hash    string    `json:"hash,omitempty"` // Hex SHA-256 of the file.
+}
+
+// Template of the directory listing.
+type listingTemplate struct {
+	tmpl *template.Template // Parsed template.
+	err  error              // Error on loading the template.
+}
+
+// Filter of the directories without listing.
+type listingFilter struct {
+	filter func(path string) bool // Filter matching the directories.
+	err    error                  // Error on parsing the patterns.
+}
+
+// Render the directory listing by the template in the served directory.
+// The template is executed with the "Listing" of the directory.
+func WithListingTemplate(page string) ServerOption {
+	return func(s *fileServer) {
+		content, err := s.fs.ReadFile(path.Clean("/" + page)[1:])
+		if err != nil {
+			s.listingTmpl = listingTemplate{err: err}
+			return
+		}
+		tmpl, err := template.New(path.Base(page)).Parse(string(content))
+		s.listingTmpl = listingTemplate{tmpl: tmpl, err: err}
+	}
+}
+
+// Disable the directory listing of the directories matching the glob
+// patterns and their subdirectories. (ex. WithListingDisabled("private"))
+func WithListingDisabled(patterns ...string) ServerOption {
+	filter, err := GlobFilter(patterns...)
+	return func(s *fileServer) {
+		s.noListingIn = listingFilter{filter: filter, err: err}
+	}
+}
+
+// Serve the listing of the directory.
+// Listing is rendered in JSON for "?format=json" or the requests accepting
+// only JSON, and sorted by "?sort=name|size|modtime&order=asc|desc".
+func (s *fileServer) serveListing(w http.ResponseWriter, r *http.Request, name string) {
+	if s.noListingIn.err != nil {
+		s.serveError(w, r, http.StatusInternalServerError, s.noListingIn.err)
+		return
+	}
+	if !s.isListingEnabled(name) {
+		s.serveError(w, r, http.StatusNotFound, fmt.Errorf("directory listing disabled"))
+		return
+	}
+	listing, err := s.listing(r, name)
+	if err != nil {
+		s.serveError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	s.setHeaders(w, name)
+	query := r.URL.Query()
+	if query.Get("format") == "json" ||
+		(query.Get("format") == "" && acceptsJSON(r) && !AcceptsHTML(r)) {
+		body, err := json.Marshal(listing)
+		if err != nil {
+			s.serveError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+		return
+	}
+	tmpl, err := s.listingTmpl.tmpl, s.listingTmpl.err
+	if err != nil {
+		s.serveError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if tmpl == nil {
+		tmpl = defaultListingTemplate
+	}
+	var body bytes.Buffer
+	err = tmpl.Execute(&body, listing)
+	if err != nil {
+		s.serveError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body.Bytes())
+}
+
+// Check if the listing of the directory is enabled.
+func (s *fileServer) isListingEnabled(name string) bool {
+	if !s.listingOn {
+		return false
+	}
+	if s.noListingIn.filter == nil {
+		return true
+	}
+	return name == "" || !s.noListingIn.filter(name)
+}
+
+// Get the sorted listing of the directory.
+func (s *fileServer) listing(r *http.Request, name string) (*Listing, error) {
+	dir, err := s.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	fileInfos, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	listing := &Listing{Path: r.URL.Path, Entries: []ListingEntry{}}
+	for _, fileInfo := range fileInfos {
+		entry := ListingEntry{
+			Name:    fileInfo.Name(),
+			IsDir:   fileInfo.IsDir(),
+			ModTime: fileInfo.ModTime(),
+		}
+		entryURL := url.URL{Path: entry.Name}
+		entry.URL = entryURL.String()
+		if entry.IsDir {
+			entry.URL += "/"
+		} else {
+			entry.Size = fileInfo.Size()
+			file, err := s.fs.GetFile(path.Join(name, entry.Name))
+			if err == nil {
+				entry.Hash = hex.EncodeToString(file.Hash())
+			}
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+	sortListing(listing.Entries, r.URL.Query().Get("sort"), r.URL.Query().Get("order"))
+	return listing, nil
+}
+
+// Sort the entries by the key. (name, size or modtime)
+// Directories are placed before the files.
+func sortListing(entries []ListingEntry, key, order string) {
+	less := func(a, b ListingEntry) bool {
+		return a.Name < b.Name
+	}
+	switch strings.ToLower(key) {
+	case "size":
+		less = func(a, b ListingEntry) bool {
+			return a.Size < b.Size
+		}
+	case "modtime":
+		less = func(a, b ListingEntry) bool {
+			return a.ModTime.Before(b.ModTime)
+		}
+	}
+	desc := strings.ToLower(order) == "desc"
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// Check if the request accepts JSON.
+func acceptsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(part, ";")[0])
+		if mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// Parsed default template of the directory listing.
+var defaultListingTemplate = template.Must(template.New("listing").Parse(DEFAULT_LISTING_TEMPLATE))
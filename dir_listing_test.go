@@ -0,0 +1,143 @@
+package zgok
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fpath "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryListing(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	os.MkdirAll(fpath.Join(srcDir, "public", "docs", "sub"), 0755)
+	os.MkdirAll(fpath.Join(srcDir, "public", "private"), 0755)
+	ioutil.WriteFile(fpath.Join(srcDir, "public", "docs", "a.txt"), []byte("aaa"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "public", "docs", "b.txt"), []byte("b"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "public", "private", "c.txt"), []byte("c"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "listing.html"),
+		[]byte(`{{.Path}}:{{range .Entries}}[{{.Name}}]{{end}}`), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "broken.html"), []byte(`{{.Missing}}`), 0644)
+	// Build zgok file.
+	outPath := "dir_listing_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "web")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	get := func(server http.Handler, target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+	// Listing is disabled by default.
+	server := zfs.FileServer("web/public")
+	if rec := get(server, "/docs/", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected [%v] got [%v]", http.StatusNotFound, rec.Code)
+	}
+	// Render by the template.
+	server = zfs.FileServer("web", WithDirectoryListing(true), WithListingTemplate("listing.html"))
+	rec := get(server, "/public/docs/", "")
+	if rec.Body.String() != "/public/docs/:[sub][a.txt][b.txt]" {
+		t.Errorf("Template:unexpected [%v]", rec.Body.String())
+	}
+	server = zfs.FileServer("web/public", WithDirectoryListing(true), WithListingTemplate("../listing.html"))
+	if rec := get(server, "/docs/", ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected [%v] got [%v]", http.StatusInternalServerError, rec.Code)
+	}
+	server = zfs.FileServer("web", WithDirectoryListing(true), WithListingTemplate("broken.html"))
+	if rec := get(server, "/public/docs/", ""); rec.Code != http.StatusInternalServerError ||
+		strings.Contains(rec.Body.String(), "<no value>") {
+		t.Errorf("expected [%v] got [%v] [%v]", http.StatusInternalServerError, rec.Code, rec.Body.String())
+	}
+	// Render in JSON sorted by size.
+	server = zfs.FileServer("web/public", WithDirectoryListing(true))
+	rec = get(server, "/docs/?format=json&sort=size&order=desc", "")
+	listing := &Listing{}
+	err = json.Unmarshal(rec.Body.Bytes(), listing)
+	if err != nil || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("JSON:unexpected [%v] error=[%v]", rec.Body.String(), err)
+	}
+	names := []string{}
+	for _, entry := range listing.Entries {
+		names = append(names, entry.Name)
+	}
+	if strings.Join(names, ",") != "sub,a.txt,b.txt" {
+		t.Errorf("Entries:unexpected [%v]", names)
+	}
+	aTxt := listing.Entries[1]
+	if aTxt.Size != 3 || aTxt.URL != "a.txt" || aTxt.ModTime.IsZero() ||
+		aTxt.Hash != "9834876dcfb05cb167a5c24953eba58c4ac89b1adf57f28f2f9d09af107ee8f0" {
+		t.Errorf("Entry:unexpected [%v]", aTxt)
+	}
+	if !listing.Entries[0].IsDir || listing.Entries[0].URL != "sub/" {
+		t.Errorf("Entry:unexpected [%v]", listing.Entries[0])
+	}
+	// Negotiate JSON by "Accept".
+	rec = get(server, "/docs/", "application/json")
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type:unexpected [%v]", rec.Header().Get("Content-Type"))
+	}
+	rec = get(server, "/docs/", "text/html,application/json")
+	if !strings.Contains(rec.Body.String(), `<a href="a.txt">a.txt</a>`) {
+		t.Errorf("HTML:unexpected [%v]", rec.Body.String())
+	}
+	// Disable listing per subtree.
+	server = zfs.FileServer("web/public", WithDirectoryListing(true),
+		WithListingDisabled("private", "docs/sub"))
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/", 200},
+		{"/docs/", 200},
+		{"/docs/sub/", 404},
+		{"/private/", 404},
+		{"/private/c.txt", 200},
+	}
+	for _, test := range tests {
+		if rec := get(server, test.path, ""); rec.Code != test.status {
+			t.Errorf("[%s]:expected [%v] got [%v]", test.path, test.status, rec.Code)
+		}
+	}
+	// Invalid patterns are errors.
+	server = zfs.FileServer("web/public", WithDirectoryListing(true), WithListingDisabled("["))
+	if rec := get(server, "/docs/", ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected [%v] got [%v]", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestSortListing(t *testing.T) {
+	entries := []ListingEntry{
+		{Name: "b", Size: 1},
+		{Name: "a", Size: 2},
+		{Name: "c", IsDir: true},
+	}
+	sortListing(entries, "", "")
+	if entries[0].Name != "c" || entries[1].Name != "a" || entries[2].Name != "b" {
+		t.Errorf("sortListing():unexpected [%v]", entries)
+	}
+	sortListing(entries, "size", "asc")
+	if entries[1].Name != "b" || entries[2].Name != "a" {
+		t.Errorf("sortListing():unexpected [%v]", entries)
+	}
+}
@@ -0,0 +1,181 @@
+package zgok
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	execMutex    sync.Mutex        // Mutex for the executable cache.
+	execCacheDir string            // Cache directory of the executable files.
+	memfdPaths   map[string]string // Memory file paths by the content hashes.
+)
+
+// Set the cache directory of the embedded executable files.
+// The user cache directory is used if it is empty.
+func SetExecCacheDir(dir string) {
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	execCacheDir = dir
+}
+
+// Get the cache directory of the embedded executable files.
+func ExecCacheDir() (string, error) {
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	return getExecCacheDir()
+}
+
+// Get the cache directory without locking.
+func getExecCacheDir() (string, error) {
+	if execCacheDir != "" {
+		return execCacheDir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, APP, "exec"), nil
+}
+
+// Remove the cached executable files not used for maxAge.
+// All the cached files are removed if maxAge is 0.
+func CleanExecCache(maxAge time.Duration) error {
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	dir, err := getExecCacheDir()
+	if err != nil {
+		return err
+	}
+	fileInfos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		if 0 < maxAge && time.Since(fileInfo.ModTime()) < maxAge {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, fileInfo.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the file into the cache directory and get its path.
+// Files are keyed by the content hashes and reused across runs.
+func (zfs *zgokFileSystem) Materialize(path string) (string, error) {
+	file, err := zfs.GetFile(path)
+	if err != nil {
+		return "", err
+	}
+	if file.FileInfo() != nil && file.FileInfo().IsDir() {
+		return "", fmt.Errorf("is a directory")
+	}
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	return materializeCache(pathBase(path), file.Bytes())
+}
+
+// Get the command to run the embedded executable file.
+// ELF executables are run from anonymous memory files on Linux, and the
+// others are materialized into the cache directory.
+func (zfs *zgokFileSystem) Command(path string, args ...string) (*exec.Cmd, error) {
+	file, err := zfs.GetFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if file.FileInfo() != nil && file.FileInfo().IsDir() {
+		return nil, fmt.Errorf("is a directory")
+	}
+	name := pathBase(path)
+	content := file.Bytes()
+	execMutex.Lock()
+	defer execMutex.Unlock()
+	var execPath string
+	if bytes.HasPrefix(content, []byte("\x7fELF")) {
+		execPath, err = memfdPath(name, content)
+	}
+	if execPath == "" || err != nil {
+		execPath, err = materializeCache(name, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cmd := exec.Command(execPath, args...)
+	cmd.Args[0] = name
+	return cmd, nil
+}
+
+// Get the base name of the path in the file system.
+func pathBase(name string) string {
+	return path.Base(filepath.ToSlash(name))
+}
+
+// Get the hex string of the content hash.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Write the content into the cache directory and get its path.
+func materializeCache(name string, content []byte) (string, error) {
+	cacheDir, err := getExecCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cacheDir, contentHash(content))
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	// Restrict the directories created before.
+	for _, d := range []string{cacheDir, dir} {
+		err = os.Chmod(d, 0700)
+		if err != nil {
+			return "", err
+		}
+	}
+	dest := filepath.Join(dir, name)
+	// Reuse the cached file with the same content.
+	cached, err := ioutil.ReadFile(dest)
+	if err == nil && bytes.Equal(cached, content) {
+		now := time.Now()
+		os.Chtimes(dir, now, now)
+		return dest, nil
+	}
+	// Write to a temporary file and rename not to expose partial content.
+	tmpFile, err := ioutil.TempFile(dir, ".tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmpFile.Write(content)
+	if err == nil {
+		err = tmpFile.Chmod(0700)
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+	return dest, nil
+}
@@ -0,0 +1,69 @@
+//go:build linux
+// +build linux
+
+package zgok
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Lowest descriptor of the memory files.
+// Kept above the descriptors passed to the child processes. (ex. Cmd.ExtraFiles)
+const MEMFD_MIN_FD = 256
+
+// System call numbers of memfd_create by architectures.
+var memfdCreateTrap = map[string]uintptr{
+	"386":     356,
+	"amd64":   319,
+	"arm":     385,
+	"arm64":   279,
+	"ppc64":   360,
+	"ppc64le": 360,
+	"riscv64": 279,
+	"s390x":   350,
+}
+
+// Write the content into an anonymous memory file and get its path.
+// Memory files are kept open and reused in the process.
+func memfdPath(name string, content []byte) (string, error) {
+	hash := contentHash(content)
+	if execPath, exists := memfdPaths[hash]; exists {
+		return execPath, nil
+	}
+	trap, exists := memfdCreateTrap[runtime.GOARCH]
+	if !exists {
+		return "", fmt.Errorf("memfd_create not supported on [%s]", runtime.GOARCH)
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	// Create with MFD_CLOEXEC not to leak into the child processes.
+	lowFd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(namePtr)), 0x0001, 0)
+	if errno != 0 {
+		return "", errno
+	}
+	// Move to the high descriptor not to be replaced in the child process.
+	fd, _, errno := syscall.Syscall(syscall.SYS_FCNTL, lowFd, syscall.F_DUPFD_CLOEXEC, MEMFD_MIN_FD)
+	syscall.Close(int(lowFd))
+	if errno != 0 {
+		return "", errno
+	}
+	for written := 0; written < len(content); {
+		n, err := syscall.Write(int(fd), content[written:])
+		if err != nil {
+			syscall.Close(int(fd))
+			return "", err
+		}
+		written += n
+	}
+	if memfdPaths == nil {
+		memfdPaths = make(map[string]string)
+	}
+	execPath := fmt.Sprintf("/proc/self/fd/%d", fd)
+	memfdPaths[hash] = execPath
+	return execPath, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package zgok
+
+import (
+	"fmt"
+)
+
+// Anonymous memory files are not supported on this platform.
+func memfdPath(name string, content []byte) (string, error) {
+	return "", fmt.Errorf("memfd_create not supported")
+}
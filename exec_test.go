@@ -0,0 +1,123 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Shell scripts are not executable on windows.")
+	}
+	cacheDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	SetExecCacheDir(fpath.Join(cacheDir, "exec"))
+	defer SetExecCacheDir("")
+	// Prepare file system with the embedded script.
+	zfs := NewFileSystem()
+	script := NewZgokFile()
+	script.SetPath("zgok/bin/greet.sh")
+	script.SetBytes([]byte("#!/bin/sh\necho \"hello $1\"\n"))
+	zfs.AddFile(script)
+	// Run the script.
+	cmd, err := zfs.Command("bin/greet.sh", "zgok")
+	if err != nil {
+		t.Fatalf("Command():error=[%v]", err)
+	}
+	output, err := cmd.Output()
+	if err != nil || string(output) != "hello zgok\n" {
+		t.Errorf("Output():unexpected [%s] error=[%v]", output, err)
+	}
+	// Cached file is reused.
+	path1, err := zfs.Materialize("bin/greet.sh")
+	if err != nil {
+		t.Fatalf("Materialize():error=[%v]", err)
+	}
+	path2, _ := zfs.Materialize("bin/greet.sh")
+	if path1 != path2 || cmd.Path != path1 {
+		t.Errorf("Materialize():expected [%v] got [%v] and [%v]", cmd.Path, path1, path2)
+	}
+	dirStat, err := os.Stat(fpath.Dir(path1))
+	if err != nil || dirStat.Mode().Perm() != 0700 {
+		t.Errorf("Cache directory:unexpected [%v] error=[%v]", dirStat, err)
+	}
+	// Modified content is rewritten.
+	ioutil.WriteFile(path1, []byte("#!/bin/sh\necho modified\n"), 0700)
+	cmd, _ = zfs.Command("bin/greet.sh", "again")
+	if output, _ = cmd.Output(); string(output) != "hello again\n" {
+		t.Errorf("Output():unexpected [%s]", output)
+	}
+	// Clean cache.
+	if err = CleanExecCache(time.Hour); err != nil {
+		t.Errorf("CleanExecCache():error=[%v]", err)
+	}
+	if _, err = os.Stat(path1); err != nil {
+		t.Errorf("Expected recently used file to be kept.")
+	}
+	if err = CleanExecCache(0); err != nil {
+		t.Errorf("CleanExecCache():error=[%v]", err)
+	}
+	if _, err = os.Stat(path1); err == nil {
+		t.Errorf("Expected cached file to be removed.")
+	}
+}
+
+func TestCommandExecutable(t *testing.T) {
+	if exePath != RealExePath {
+		t.Skip("Executable is not compiled.")
+	}
+	content, err := ioutil.ReadFile(exePath)
+	if err != nil {
+		t.Fatalf("ReadFile():error=[%v]", err)
+	}
+	cacheDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	SetExecCacheDir(cacheDir)
+	defer SetExecCacheDir("")
+	zfs := NewFileSystem()
+	hello := NewZgokFile()
+	hello.SetPath("zgok/bin/hello")
+	hello.SetBytes(content)
+	zfs.AddFile(hello)
+	for i := 0; i < 2; i++ {
+		cmd, err := zfs.Command("bin/hello")
+		if err != nil {
+			t.Fatalf("Command():error=[%v]", err)
+		}
+		if runtime.GOOS == "linux" && !strings.HasPrefix(cmd.Path, "/proc/self/fd/") {
+			t.Errorf("Expected memory file on linux got [%v].", cmd.Path)
+		}
+		output, err := cmd.Output()
+		if err != nil || string(output) != "hello" {
+			t.Errorf("Output():unexpected [%s] error=[%v]", output, err)
+		}
+	}
+	// Descriptors passed to the child don't replace the memory file.
+	cmd, err := zfs.Command("bin/hello")
+	if err != nil {
+		t.Fatalf("Command():error=[%v]", err)
+	}
+	for i := 0; i < 16; i++ {
+		extraFile, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatalf("Open():error=[%v]", err)
+		}
+		defer extraFile.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, extraFile)
+	}
+	output, err := cmd.Output()
+	if err != nil || string(output) != "hello" {
+		t.Errorf("Output():unexpected [%s] error=[%v]", output, err)
+	}
+}
@@ -0,0 +1,294 @@
+package zgok
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Policy for the existing files on extraction.
+type ExtractPolicy int
+
+const (
+	EXTRACT_OVERWRITE ExtractPolicy = iota // Replace the existing files.
+	EXTRACT_SKIP                           // Keep the existing files.
+	EXTRACT_FAIL                           // Fail on the existing files.
+)
+
+// Parse the extract policy name. (overwrite, skip or fail)
+func ParseExtractPolicy(name string) (ExtractPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "overwrite":
+		return EXTRACT_OVERWRITE, nil
+	case "skip":
+		return EXTRACT_SKIP, nil
+	case "fail":
+		return EXTRACT_FAIL, nil
+	}
+	return EXTRACT_OVERWRITE, fmt.Errorf("unknown extract policy [%s]", name)
+}
+
+// Create a filter matching the paths by the glob patterns.
+// A path matches if it or its parent directory matches any of the patterns.
+// All the paths match if no patterns are given.
+func GlobFilter(patterns ...string) (func(path string) bool, error) {
+	if err := validatePatterns(patterns); err != nil {
+		return nil, err
+	}
+	filter := func(relPath string) bool {
+		if len(patterns) == 0 {
+			return true
+		}
+		for p := relPath; p != "." && p != "/"; p = path.Dir(p) {
+			for _, pattern := range patterns {
+				if matched, _ := path.Match(path.Clean(pattern), p); matched {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return filter, nil
+}
+
+// Extract the files passing the filter into the directory.
+// Modes and modification times of the files are preserved. Paths and
+// symlinks escaping the directory are rejected. All the files are extracted
+// if the filter is nil.
+func (zfs *zgokFileSystem) ExtractTo(dir string, filter func(path string) bool, policy ExtractPolicy) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	var dirs, links []fsEntry
+	for _, entry := range fileSystemEntries(zfs) {
+		if filter != nil && !filter(entry.relPath) {
+			continue
+		}
+		switch {
+		case entry.file.FileInfo() != nil && entry.file.FileInfo().IsDir():
+			dirs = append(dirs, entry)
+		case isSymlink(entry.file):
+			links = append(links, entry)
+		default:
+			err = extractFile(dir, entry, policy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Create symlinks after the files not to write through them.
+	linkPaths := make(map[string]bool)
+	for _, entry := range links {
+		linkPaths[entry.relPath] = true
+	}
+	for _, entry := range links {
+		err = extractSymlink(dir, entry, policy, linkPaths)
+		if err != nil {
+			return err
+		}
+	}
+	// Set directory times after their contents are written.
+	for i := len(dirs) - 1; 0 <= i; i-- {
+		err = extractDir(dir, dirs[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract the files passing the filter into a new temporary directory.
+// The caller should remove the returned directory after use.
+func (zfs *zgokFileSystem) ExtractTemp(filter func(path string) bool) (string, error) {
+	dir, err := ioutil.TempDir("", APP)
+	if err != nil {
+		return "", err
+	}
+	err = zfs.ExtractTo(dir, filter, EXTRACT_FAIL)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// Get the destination path of the entry in the directory.
+// Parent directories are created, and the path must not escape the directory
+// through parent references or existing symlinks.
+func extractPath(dir, relPath string) (string, error) {
+	cleaned := path.Clean(relPath)
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." ||
+		strings.HasPrefix(cleaned, "../") {
+		return "", &UnsafePathError{Name: relPath, Reason: "escapes the target directory"}
+	}
+	dest := dir
+	elems := strings.Split(cleaned, "/")
+	for _, elem := range elems[:len(elems)-1] {
+		dest = filepath.Join(dest, elem)
+		fileInfo, err := os.Lstat(dest)
+		if os.IsNotExist(err) {
+			err = os.Mkdir(dest, 0755)
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if !fileInfo.IsDir() {
+			return "", &UnsafePathError{Name: relPath, Reason: "parent is not a directory"}
+		}
+	}
+	return filepath.Join(dest, elems[len(elems)-1]), nil
+}
+
+// Check the existing file at the destination by the policy.
+// Returns true if the entry should be written.
+func checkExisting(dest string, policy ExtractPolicy) (bool, error) {
+	fileInfo, err := os.Lstat(dest)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch policy {
+	case EXTRACT_SKIP:
+		return false, nil
+	case EXTRACT_FAIL:
+		return false, &os.PathError{Op: "extract", Path: dest, Err: os.ErrExist}
+	}
+	if fileInfo.IsDir() {
+		return false, &os.PathError{Op: "extract", Path: dest, Err: fmt.Errorf("is a directory")}
+	}
+	// Remove not to write through the existing symlink.
+	return true, os.Remove(dest)
+}
+
+// Extract the regular file.
+func extractFile(dir string, entry fsEntry, policy ExtractPolicy) error {
+	dest, err := extractPath(dir, entry.relPath)
+	if err != nil {
+		return err
+	}
+	write, err := checkExisting(dest, policy)
+	if err != nil || !write {
+		return err
+	}
+	perm := os.FileMode(0644)
+	fileInfo := entry.file.FileInfo()
+	if fileInfo != nil {
+		perm = fileInfo.Mode().Perm()
+	}
+	file, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(entry.file.Bytes())
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	// Preserve the mode regardless of umask.
+	err = os.Chmod(dest, perm)
+	if err != nil {
+		return err
+	}
+	if fileInfo != nil && !fileInfo.ModTime().IsZero() {
+		return os.Chtimes(dest, fileInfo.ModTime(), fileInfo.ModTime())
+	}
+	return nil
+}
+
+// Extract the symlink.
+func extractSymlink(dir string, entry fsEntry, policy ExtractPolicy, linkPaths map[string]bool) error {
+	target := string(entry.file.Bytes())
+	err := checkSymlinkTarget(dir, entry.relPath, target, linkPaths)
+	if err != nil {
+		return err
+	}
+	dest, err := extractPath(dir, entry.relPath)
+	if err != nil {
+		return err
+	}
+	write, err := checkExisting(dest, policy)
+	if err != nil || !write {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(target), dest)
+}
+
+// Check the symlink target resolves inside the directory.
+// Targets going through other symlinks (in the payload or on the disk) are
+// rejected, because the chained links can escape the directory even if each
+// link stays inside by itself.
+func checkSymlinkTarget(dir, relPath, target string, linkPaths map[string]bool) error {
+	if path.IsAbs(target) {
+		return &UnsafePathError{Name: relPath, Reason: "symlink escapes the target directory"}
+	}
+	var resolved []string
+	if parent := path.Dir(relPath); parent != "." {
+		resolved = strings.Split(parent, "/")
+	}
+	elems := strings.Split(target, "/")
+	for i, elem := range elems {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return &UnsafePathError{Name: relPath, Reason: "symlink escapes the target directory"}
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		resolved = append(resolved, elem)
+		if i == len(elems)-1 {
+			break
+		}
+		// Intermediate elements must not be symlinks.
+		current := strings.Join(resolved, "/")
+		fileInfo, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(current)))
+		if linkPaths[current] || (err == nil && fileInfo.Mode()&os.ModeSymlink != 0) {
+			return &UnsafePathError{Name: relPath, Reason: "symlink target goes through another symlink"}
+		}
+	}
+	return nil
+}
+
+// Extract the directory.
+func extractDir(dir string, entry fsEntry) error {
+	dest, err := extractPath(dir, entry.relPath)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := os.Lstat(dest)
+	if os.IsNotExist(err) {
+		err = os.Mkdir(dest, entry.file.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if !fileInfo.IsDir() {
+		return &os.PathError{Op: "extract", Path: dest, Err: fmt.Errorf("not a directory")}
+	}
+	err = os.Chmod(dest, entry.file.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	modTime := entry.file.FileInfo().ModTime()
+	if !modTime.IsZero() {
+		return os.Chtimes(dest, modTime, modTime)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtractTo(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	os.MkdirAll(fpath.Join(srcDir, "bin"), 0755)
+	ioutil.WriteFile(fpath.Join(srcDir, "bin", "tool"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(fpath.Join(srcDir, "foo"), []byte("foo"), 0600)
+	os.Symlink("foo", fpath.Join(srcDir, "link"))
+	modTime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.Local)
+	os.Chtimes(fpath.Join(srcDir, "foo"), modTime, modTime)
+	// Build zgok file.
+	outPath := "extract_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "app")
+	builder.SetSymlinkMode(SYMLINK_PRESERVE)
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	// Extract all files.
+	dir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(dir)
+	err = zfs.ExtractTo(dir, nil, EXTRACT_FAIL)
+	if err != nil {
+		t.Fatalf("ExtractTo():error=[%v]", err)
+	}
+	toolStat, err := os.Stat(fpath.Join(dir, "app", "bin", "tool"))
+	if err != nil || toolStat.Mode().Perm() != 0755 {
+		t.Errorf("Extracted tool:unexpected [%v] error=[%v]", toolStat, err)
+	}
+	fooStat, err := os.Stat(fpath.Join(dir, "app", "foo"))
+	if err != nil || fooStat.Mode().Perm() != 0600 || !fooStat.ModTime().Equal(modTime) {
+		t.Errorf("Extracted foo:unexpected [%v] error=[%v]", fooStat, err)
+	}
+	target, err := os.Readlink(fpath.Join(dir, "app", "link"))
+	if err != nil || target != "foo" {
+		t.Errorf("Extracted link:unexpected [%v] error=[%v]", target, err)
+	}
+	// Check policies.
+	err = zfs.ExtractTo(dir, nil, EXTRACT_FAIL)
+	if !os.IsExist(err) {
+		t.Errorf("Expected exist error got [%v].", err)
+	}
+	ioutil.WriteFile(fpath.Join(dir, "app", "foo"), []byte("changed"), 0600)
+	err = zfs.ExtractTo(dir, nil, EXTRACT_SKIP)
+	if err != nil {
+		t.Errorf("ExtractTo():error=[%v]", err)
+	}
+	if content, _ := ioutil.ReadFile(fpath.Join(dir, "app", "foo")); string(content) != "changed" {
+		t.Errorf(`expected "changed" got "%s"`, content)
+	}
+	err = zfs.ExtractTo(dir, nil, EXTRACT_OVERWRITE)
+	if err != nil {
+		t.Errorf("ExtractTo():error=[%v]", err)
+	}
+	if content, _ := ioutil.ReadFile(fpath.Join(dir, "app", "foo")); string(content) != "foo" {
+		t.Errorf(`expected "foo" got "%s"`, content)
+	}
+	// Extract filtered files into temp directory.
+	filter, err := GlobFilter("app/bin")
+	if err != nil {
+		t.Fatalf("GlobFilter():error=[%v]", err)
+	}
+	tempDir, err := zfs.ExtractTemp(filter)
+	if err != nil {
+		t.Fatalf("ExtractTemp():error=[%v]", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if _, err = os.Stat(fpath.Join(tempDir, "app", "bin", "tool")); err != nil {
+		t.Errorf("Stat():error=[%v]", err)
+	}
+	if _, err = os.Lstat(fpath.Join(tempDir, "app", "foo")); err == nil {
+		t.Errorf("Expected filtered file not to be extracted.")
+	}
+}
+
+func TestExtractToUnsafe(t *testing.T) {
+	// Symlink escaping the directory.
+	zfs := NewFileSystem()
+	link := NewZgokFile()
+	link.SetPath("zgok/link")
+	link.SetFileInfo(zgokFileInfo{name: "link", mode: os.ModeSymlink | 0777})
+	link.SetBytes([]byte("../outside"))
+	zfs.AddFile(link)
+	dir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = zfs.ExtractTo(dir, nil, EXTRACT_FAIL); err == nil {
+		t.Errorf("Expected error on symlink escaping the directory.")
+	}
+	// Existing symlink in the destination.
+	zfs = NewFileSystem()
+	file := NewZgokFile()
+	file.SetPath("zgok/sub/foo")
+	file.SetBytes([]byte("foo"))
+	zfs.AddFile(file)
+	os.Symlink(os.TempDir(), fpath.Join(dir, "sub"))
+	if err = zfs.ExtractTo(dir, nil, EXTRACT_OVERWRITE); err == nil {
+		t.Errorf("Expected error on writing through symlink.")
+	}
+	// Chained symlinks escaping the directory.
+	newLinks := func(targets map[string]string) FileSystem {
+		zfs := NewFileSystem()
+		for name, target := range targets {
+			link := NewZgokFile()
+			link.SetPath("zgok/" + name)
+			link.SetFileInfo(zgokFileInfo{name: fpath.Base(name), mode: os.ModeSymlink | 0777})
+			link.SetBytes([]byte(target))
+			zfs.AddFile(link)
+		}
+		return zfs
+	}
+	chainDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(chainDir)
+	zfs = newLinks(map[string]string{"a/b/u": "../..", "v": "a/b/u/.."})
+	if err = zfs.ExtractTo(chainDir, nil, EXTRACT_FAIL); err == nil {
+		t.Errorf("Expected error on chained symlinks escaping the directory.")
+	}
+	if _, err = os.Lstat(fpath.Join(chainDir, "v")); err == nil {
+		t.Errorf("Expected chained symlink not to be extracted.")
+	}
+	// Symlink to a symlink inside the directory.
+	os.RemoveAll(chainDir)
+	zfs = newLinks(map[string]string{"a/b/u": "../..", "w": "a/b/u"})
+	if err = zfs.ExtractTo(chainDir, nil, EXTRACT_FAIL); err != nil {
+		t.Errorf("ExtractTo():error=[%v]", err)
+	}
+}
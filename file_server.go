@@ -1,15 +1,548 @@
 package zgok
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Precompressed sibling of the file.
+type precompressed struct {
+	encoding string // Content encoding.
+	ext      string // Extension of the sibling.
+}
+
+// Precompressed siblings in the preferred order.
+var precompressedSiblings = []precompressed{
+	{encoding: "br", ext: ".br"},
+	{encoding: "gzip", ext: ".gz"},
+}
+
+// Static file server.
+// Sends ETag from the content hash, and responds 304 for the requests
+// with the matching "If-None-Match". Precompressed siblings are served
+// to the clients accepting their encodings.
+type fileServer struct {
+	fs            FileSystem        // File system to serve.
+	handler       http.Handler      // Handler serving the directories and the errors.
+	cacheControls []cacheControl    // Cache-Control policies.
+	modTime       time.Time         // Last-Modified of the files. (Zero for the file times)
+	fallback      string            // Fallback file for the SPA routes.
+	fallbackRules []FallbackRule    // Rules to serve the fallback file.
+	errorPages    map[int]string    // Error pages by the status codes.
+	errorHandler  ErrorHandler      // Handler of the error responses.
+	listingOn     bool              // Is the directory listing enabled?
+	noListingIn   listingFilter     // Filter of the directories without listing.
+	listingTmpl   listingTemplate   // Template of the directory listing.
+	fingerprints  map[string]string // Logical paths by the fingerprinted paths.
+	contentTypes  map[string]string // Content types overridden by the extensions.
+	headers       []staticHeader    // Static headers.
+	cors          *CORS             // CORS policy. (nil for no CORS headers)
+	observers     []Observer        // Observers of the requests.
+}
+
+// Handler of the error responses.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// Rule to serve the fallback file for the request of the missing path.
+type FallbackRule func(r *http.Request) bool
+
+// Cache-Control policy for the paths matching the glob pattern.
+type cacheControl struct {
+	pattern string // Glob pattern of the paths.
+	value   string // Value of the Cache-Control header.
+}
+
+// File server option.
+type ServerOption func(s *fileServer)
+
+// Set Cache-Control for the paths matching the glob pattern.
+// Patterns are matched against the path and the base name, and the first
+// matching policy is used.
+// (ex. WithCacheControl("*.js", "public, max-age=31536000, immutable"))
+func WithCacheControl(pattern, value string) ServerOption {
+	return func(s *fileServer) {
+		s.cacheControls = append(s.cacheControls, cacheControl{pattern: pattern, value: value})
+	}
+}
+
+// Set Last-Modified of all the files.
+// The build time is used by default, and the modified times of the files
+// are used if the time is zero.
+func WithModTime(modTime time.Time) ServerOption {
+	return func(s *fileServer) {
+		s.modTime = modTime
+	}
+}
+
+// Serve the fallback file (ex. "index.html") for the missing paths of the
+// single-page application routes. The fallback is served if any of the rules
+// matches the request. Requests accepting HTML or the paths without extension
+// are matched if no rules are given, and the other missing paths are 404.
+func WithSPAFallback(fallback string, rules ...FallbackRule) ServerOption {
+	return func(s *fileServer) {
+		s.fallback = path.Clean("/" + fallback)[1:]
+		s.fallbackRules = rules
+		if len(rules) == 0 {
+			s.fallbackRules = []FallbackRule{AcceptsHTML, HasNoExtension}
+		}
+	}
+}
+
+// Check if the request accepts HTML. (ex. navigation of the browsers)
+func AcceptsHTML(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(part, ";")[0])
+		if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the request path has no extension.
+func HasNoExtension(r *http.Request) bool {
+	return path.Ext(r.URL.Path) == ""
+}
+
+// Serve the page in the file system for the error status.
+// (ex. WithErrorPage(http.StatusNotFound, "404.html"))
+func WithErrorPage(status int, page string) ServerOption {
+	return func(s *fileServer) {
+		s.errorPages[status] = path.Clean("/" + page)[1:]
+	}
+}
+
+// Call the handler for the error responses instead of the error pages.
+func WithErrorHandler(handler ErrorHandler) ServerOption {
+	return func(s *fileServer) {
+		s.errorHandler = handler
+	}
+}
+
+// Enable or disable the directory listing. (Disabled by default)
+// Directories without the index files are 404 if it is disabled.
+func WithDirectoryListing(enabled bool) ServerOption {
+	return func(s *fileServer) {
+		s.listingOn = enabled
+	}
+}
+
 // Get a static file server.
-func (zfs *zgokFileSystem) FileServer(basePath string) http.Handler {
-	var server http.Handler
+func (zfs *zgokFileSystem) FileServer(basePath string, options ...ServerOption) http.Handler {
 	subFs, err := zfs.SubFileSystem(basePath)
-	if err == nil {
-		server = http.FileServer(subFs)
+	if err != nil {
+		return nil
+	}
+	s := &fileServer{
+		fs:           subFs,
+		handler:      http.FileServer(subFs),
+		modTime:      zfs.BuildTime(),
+		errorPages:   make(map[int]string),
+		contentTypes: make(map[string]string),
+	}
+	manifest, err := subFs.AssetManifest()
+	if err != nil {
+		return nil
+	}
+	s.fingerprints = make(map[string]string)
+	for logical, fingerprinted := range manifest {
+		s.fingerprints[fingerprinted] = logical
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Serve the file.
+// Implements [net/http.Handler.ServeHTTP]
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(s.observers) == 0 {
+		s.serve(w, r)
+		return
+	}
+	start := time.Now()
+	recorder := &responseRecorder{ResponseWriter: w}
+	s.serve(recorder, r)
+	s.observe(r, recorder, time.Since(start))
+}
+
+// Serve the file, the directory listing or the error.
+func (s *fileServer) serve(w http.ResponseWriter, r *http.Request) {
+	if s.handleCORS(w, r) {
+		return
+	}
+	// Serve the fingerprinted path as immutable.
+	if logical, exists := s.fingerprints[path.Clean("/" + r.URL.Path)[1:]]; exists {
+		if file, err := s.fs.GetFile(logical); err == nil && isRegular(file) {
+			w.Header().Set("Cache-Control", IMMUTABLE_CACHE_CONTROL)
+			s.serveFile(w, r, logical, file)
+			return
+		}
+	}
+	target, err := s.stat(path.Clean("/" + r.URL.Path)[1:])
+	if err != nil {
+		if s.isFallback(r) {
+			fallback, _ := s.fs.GetFile(s.fallback)
+			s.serveFile(w, r, s.fallback, fallback)
+			return
+		}
+		s.serveError(w, r, http.StatusNotFound, err)
+		return
+	}
+	name, file, isIndex := s.lookup(r.URL.Path)
+	if file != nil && !isRedirected(r.URL.Path, isIndex) {
+		s.serveFile(w, r, name, file)
+		return
+	}
+	// Serve the directory listing.
+	isDir := target.FileInfo() != nil && target.FileInfo().IsDir()
+	if file == nil && isDir && strings.HasSuffix(r.URL.Path, "/") {
+		s.serveListing(w, r, path.Clean("/" + r.URL.Path)[1:])
+		return
+	}
+	// Serve the redirects.
+	if file != nil {
+		s.setHeaders(w, redirectedName(name))
+	} else {
+		s.setHeaders(w, path.Clean("/" + r.URL.Path)[1:])
+	}
+	if s.errorHandler == nil && len(s.errorPages) == 0 {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+	interceptor := &errorInterceptor{ResponseWriter: w}
+	s.handler.ServeHTTP(interceptor, r)
+	if interceptor.status != 0 {
+		s.serveError(w, r, interceptor.status, errors.New(http.StatusText(interceptor.status)))
+	}
+}
+
+// Serve the error response.
+// The error handler or the error page for the status is used if it is set.
+func (s *fileServer) serveError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if s.errorHandler != nil {
+		s.setHeaders(w, path.Clean("/" + r.URL.Path)[1:])
+		s.errorHandler(w, r, status, err)
+		return
+	}
+	if page, exists := s.errorPages[status]; exists {
+		file, err := s.fs.GetFile(page)
+		if err == nil && isRegular(file) {
+			s.setHeaders(w, page)
+			header := w.Header()
+			header.Del("ETag")
+			header.Del("Last-Modified")
+			header.Set("Content-Type", s.contentType(page, file))
+			header.Set("Content-Length", strconv.Itoa(len(file.Bytes())))
+			w.WriteHeader(status)
+			if r.Method != "HEAD" {
+				w.Write(file.Bytes())
+			}
+			return
+		}
+	}
+	s.setHeaders(w, path.Clean("/" + r.URL.Path)[1:])
+	if status == http.StatusNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// Response writer intercepting the error responses.
+type errorInterceptor struct {
+	http.ResponseWriter
+	status int // Status code of the intercepted error.
+}
+
+// Write the status code unless it is an error.
+// Implements [net/http.ResponseWriter.WriteHeader]
+func (ei *errorInterceptor) WriteHeader(status int) {
+	if 400 <= status {
+		ei.status = status
+		return
+	}
+	ei.ResponseWriter.WriteHeader(status)
+}
+
+// Write the body unless the error is intercepted.
+// Implements [net/http.ResponseWriter.Write]
+func (ei *errorInterceptor) Write(p []byte) (int, error) {
+	if ei.status != 0 {
+		return len(p), nil
 	}
-	return server
+	return ei.ResponseWriter.Write(p)
+}
+
+// Check if the fallback file is served for the request.
+func (s *fileServer) isFallback(r *http.Request) bool {
+	if s.fallback == "" || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+	// Missing fallback is not served.
+	if file, err := s.fs.GetFile(s.fallback); err != nil || !isRegular(file) {
+		return false
+	}
+	for _, rule := range s.fallbackRules {
+		if rule(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve the regular file.
+func (s *fileServer) serveFile(w http.ResponseWriter, r *http.Request, name string, file File) {
+	s.setHeaders(w, name)
+	if recorder, ok := w.(*responseRecorder); ok {
+		recorder.file = name
+	}
+	if value := s.cacheControl(name); value != "" {
+		w.Header().Set("Cache-Control", value)
+	}
+	// Serve the precompressed content.
+	siblings := s.siblings(name)
+	if len(siblings) > 0 || file.Deflate() != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	for _, sibling := range precompressedSiblings {
+		encoded, exists := siblings[sibling.encoding]
+		if exists && acceptsEncoding(acceptEncoding, sibling.encoding) {
+			s.serveContent(w, r, name, file, sibling.encoding,
+				fileETag(encoded), bytes.NewReader(encoded.Bytes()))
+			return
+		}
+	}
+	// Wrap the deflate stream in the payload without recompressing.
+	if file.Deflate() != nil && acceptsEncoding(acceptEncoding, "gzip") {
+		etag := `"` + hex.EncodeToString(file.Hash()) + `-gzip"`
+		s.serveContent(w, r, name, file, "gzip", etag, newGzipReader(file))
+		return
+	}
+	s.serveContent(w, r, name, file, "", fileETag(file), bytes.NewReader(file.Bytes()))
+}
+
+// Serve the content of the file in the encoding. (Empty for identity)
+func (s *fileServer) serveContent(w http.ResponseWriter, r *http.Request, name string,
+	file File, encoding, etag string, content io.ReadSeeker) {
+	header := w.Header()
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	header.Set("Content-Type", s.contentType(name, file))
+	header.Set("ETag", etag)
+	modTime := s.modTime
+	if modTime.IsZero() && file.FileInfo() != nil {
+		modTime = file.FileInfo().ModTime()
+	}
+	// Preconditions of ETag and Last-Modified are checked.
+	http.ServeContent(w, r, name, modTime, content)
+}
+
+// Get Cache-Control for the path.
+func (s *fileServer) cacheControl(name string) string {
+	for _, policy := range s.cacheControls {
+		if isExcluded(name, "", []string{policy.pattern}) {
+			return policy.value
+		}
+	}
+	return ""
+}
+
+// Get the file served for the URL path.
+// Returns nil if no regular file is served.
+func (s *fileServer) lookup(urlPath string) (string, File, bool) {
+	name := path.Clean("/" + urlPath)[1:]
+	file, err := s.stat(name)
+	if err != nil {
+		return "", nil, false
+	}
+	// Directory is served by its index file.
+	isIndex := false
+	if file.FileInfo() != nil && file.FileInfo().IsDir() {
+		name = path.Join(name, "index.html")
+		file, err = s.fs.GetFile(name)
+		if err != nil {
+			return "", nil, false
+		}
+		isIndex = true
+	}
+	if !isRegular(file) {
+		return "", nil, false
+	}
+	return name, file, isIndex
+}
+
+// Get the file, or the directory implied by the paths of the files.
+func (s *fileServer) stat(name string) (File, error) {
+	handle, err := s.fs.Open("/" + name)
+	if err != nil {
+		return nil, err
+	}
+	handle.Close()
+	file, ok := handle.(File)
+	if !ok {
+		return nil, fmt.Errorf("unknown file type [%s]", name)
+	}
+	return file, nil
+}
+
+// Get the precompressed siblings of the file by the encodings.
+func (s *fileServer) siblings(name string) map[string]File {
+	siblings := make(map[string]File)
+	for _, sibling := range precompressedSiblings {
+		file, err := s.fs.GetFile(name + sibling.ext)
+		if err != nil {
+			continue
+		}
+		if !isRegular(file) {
+			continue
+		}
+		siblings[sibling.encoding] = file
+	}
+	return siblings
+}
+
+// Check if the file is a regular file.
+func isRegular(file File) bool {
+	return file.FileInfo() == nil || file.FileInfo().Mode().IsRegular()
+}
+
+// Check if the underlying handler redirects the URL path.
+func isRedirected(urlPath string, isIndex bool) bool {
+	if isIndex {
+		return !strings.HasSuffix(urlPath, "/")
+	}
+	return strings.HasSuffix(urlPath, "/") || strings.HasSuffix(urlPath, "/index.html")
+}
+
+// Get the name of the redirect target of the file.
+// Index files are redirected to their directories.
+func redirectedName(name string) string {
+	if path.Base(name) != "index.html" {
+		return name
+	}
+	if dir := path.Dir(name); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// Get the reader of the gzip stream wrapping the raw deflate stream.
+// The trailer is made of CRC-32 and the size of the content.
+func newGzipReader(file File) io.ReadSeeker {
+	// Header without the file name and the modified time. (OS: unknown)
+	header := []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255}
+	trailer := make([]byte, 8)
+	binary.LittleEndian.PutUint32(trailer[0:], file.CRC32())
+	binary.LittleEndian.PutUint32(trailer[4:], uint32(len(file.Bytes())))
+	return newSegmentsReader(header, file.Deflate(), trailer)
+}
+
+// Reader of the byte segments as a continuous stream.
+type segmentsReader struct {
+	segments [][]byte // Byte segments.
+	size     int64    // Total byte size.
+	offset   int64    // Current offset.
+}
+
+// Create a new reader of the byte segments.
+func newSegmentsReader(segments ...[]byte) *segmentsReader {
+	r := &segmentsReader{segments: segments}
+	for _, segment := range segments {
+		r.size += int64(len(segment))
+	}
+	return r
+}
+
+// Read bytes.
+// Implements [io.Reader]
+func (r *segmentsReader) Read(p []byte) (int, error) {
+	if r.size <= r.offset {
+		return 0, io.EOF
+	}
+	n := 0
+	start := int64(0)
+	for _, segment := range r.segments {
+		end := start + int64(len(segment))
+		if r.offset < end && n < len(p) {
+			copied := copy(p[n:], segment[r.offset-start:])
+			n += copied
+			r.offset += int64(copied)
+		}
+		start = end
+	}
+	return n, nil
+}
+
+// Seek offset.
+// Implements [io.Seeker]
+func (r *segmentsReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence")
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Get the content type of the file.
+// The overridden type by the extension, or the stored type is used.
+func (s *fileServer) contentType(name string, file File) string {
+	if ctype, exists := s.contentTypes[strings.ToLower(path.Ext(name))]; exists {
+		return ctype
+	}
+	return file.ContentType()
+}
+
+// Check if the "Accept-Encoding" header accepts the encoding.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding != encoding && coding != "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					quality = q
+				}
+			}
+		}
+		// Exact coding takes precedence over "*".
+		if coding == encoding {
+			return 0 < quality
+		}
+		accepted = 0 < quality
+	}
+	return accepted
+}
+
+// Get the strong ETag of the file from its content hash.
+func fileETag(file File) string {
+	return `"` + hex.EncodeToString(file.Hash()) + `"`
 }
@@ -1,10 +1,20 @@
 package zgok
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	fpath "path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFileServer(t *testing.T) {
@@ -42,6 +52,11 @@ func TestFileServer(t *testing.T) {
 	if fooBody != "foo" {
 		t.Errorf(`expected "foo" got "%v"`, fooBody)
 	}
+	// Check "Last-Modified" of "foo" is the build time.
+	lastModified := zfs.BuildTime().UTC().Format(http.TimeFormat)
+	if zfs.BuildTime().IsZero() || res.Header.Get("Last-Modified") != lastModified {
+		t.Errorf(`expected "%v" got "%v"`, lastModified, res.Header.Get("Last-Modified"))
+	}
 	// Get "dir/bar"
 	res, err = http.Get(ts.URL + "/dir/bar")
 	if err != nil {
@@ -56,3 +71,506 @@ func TestFileServer(t *testing.T) {
 		t.Errorf(`expected "bar" got "%v"`, barBody)
 	}
 }
+
+func TestFileServerETag(t *testing.T) {
+	// Build zgok file.
+	outPath := "file_server_etag_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.SetOutPath(outPath)
+	builder.Build()
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	// Check digests of "foo".
+	foo, err := zfs.GetFile("testdata/foo")
+	if err != nil {
+		t.Fatalf("GetFile():error=[%v]", err)
+	}
+	sum := sha256.Sum256([]byte("foo"))
+	if !bytes.Equal(foo.Hash(), sum[:]) {
+		t.Errorf("Hash():expected [%x] got [%x]", sum, foo.Hash())
+	}
+	if foo.CRC32() != crc32.ChecksumIEEE([]byte("foo")) {
+		t.Errorf("CRC32():unexpected [%v]", foo.CRC32())
+	}
+	// Get "foo" with ETag.
+	ts := httptest.NewServer(zfs.FileServer("testdata"))
+	defer ts.Close()
+	res, err := http.Get(ts.URL + "/foo")
+	if err != nil {
+		t.Fatalf("Get [/foo] failed.")
+	}
+	res.Body.Close()
+	etag := fmt.Sprintf(`"%x"`, sum)
+	if res.Header.Get("ETag") != etag {
+		t.Errorf(`expected "%v" got "%v"`, etag, res.Header.Get("ETag"))
+	}
+	// Get "foo" with "If-None-Match".
+	req, _ := http.NewRequest("GET", ts.URL+"/foo", nil)
+	req.Header.Set("If-None-Match", etag)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Get [/foo] failed.")
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected [%v] got [%v]", http.StatusNotModified, res.StatusCode)
+	}
+	req.Header.Set("If-None-Match", `"other"`)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Get [/foo] failed.")
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected [%v] got [%v]", http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestFileServerPrecompressed(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	appJs := strings.Repeat("console.log('zgok');\n", 100)
+	ioutil.WriteFile(fpath.Join(srcDir, "app.js"), []byte(appJs), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "style.css"), []byte("body {}"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "style.css.br"), []byte("brotli"), 0644)
+	// Build zgok file.
+	outPath := "file_server_precompressed_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "public")
+	builder.SetPrecompress("*.js", "*.css")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	if _, err = zfs.GetFile("public/style.css.gz"); err == nil {
+		t.Errorf("Expected no gzip sibling for the small file.")
+	}
+	ts := httptest.NewServer(zfs.FileServer("public"))
+	defer ts.Close()
+	// Disable transparent decompression of the client.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(urlPath, acceptEncoding string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("GET", ts.URL+urlPath, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Get [%s] failed.", urlPath)
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return res, body
+	}
+	// Get gzip variant.
+	res, body := get("/app.js", "gzip, deflate")
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip got [%v]", res.Header.Get("Content-Encoding"))
+	}
+	if res.Header.Get("Vary") != "Accept-Encoding" ||
+		!strings.HasPrefix(res.Header.Get("Content-Type"), "text/javascript") ||
+		res.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("Header:unexpected [%v]", res.Header)
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader():error=[%v]", err)
+	}
+	decoded, _ := ioutil.ReadAll(gzipReader)
+	if string(decoded) != appJs {
+		t.Errorf("Decoded body:unexpected [%s]", decoded)
+	}
+	// Get identity.
+	res, body = get("/app.js", "gzip;q=0, br;q=0")
+	if res.Header.Get("Content-Encoding") != "" || string(body) != appJs {
+		t.Errorf("Identity:unexpected [%v]", res.Header)
+	}
+	if res.Header.Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary:unexpected [%v]", res.Header.Get("Vary"))
+	}
+	// Get brotli variant given in the input.
+	res, body = get("/style.css", "gzip, br")
+	if res.Header.Get("Content-Encoding") != "br" || string(body) != "brotli" {
+		t.Errorf("Brotli:unexpected [%v] [%s]", res.Header, body)
+	}
+	res, body = get("/style.css", "gzip")
+	if res.Header.Get("Content-Encoding") != "" || string(body) != "body {}" {
+		t.Errorf("Identity:unexpected [%v] [%s]", res.Header, body)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		expected bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip;q=0.5", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"*", "gzip", true},
+		{"*;q=0, gzip", "gzip", true},
+		{"br, *;q=0", "gzip", false},
+		{"", "gzip", false},
+	}
+	for _, test := range tests {
+		if actual := acceptsEncoding(test.header, test.encoding); actual != test.expected {
+			t.Errorf("acceptsEncoding(%q, %q):expected [%v] got [%v]",
+				test.header, test.encoding, test.expected, actual)
+		}
+	}
+}
+
+func TestFileServerDeflateAsGzip(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	indexHtml := strings.Repeat("<p>zgok</p>\n", 100)
+	ioutil.WriteFile(fpath.Join(srcDir, "index.html"), []byte(indexHtml), 0644)
+	// Build zgok file.
+	outPath := "file_server_deflate_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "public")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	// Raw deflate streams are kept only by the option.
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	if file, _ := zfs.GetFile("public/index.html"); file.Deflate() != nil {
+		t.Errorf("Expected no deflate stream without the option.")
+	}
+	zfs, err = RestoreFileSystem(outPath, WithDeflateStreams())
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	ts := httptest.NewServer(zfs.FileServer("public"))
+	defer ts.Close()
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	// Get the deflate stream wrapped in gzip.
+	req, _ := http.NewRequest("GET", ts.URL+"/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Get [/] failed.")
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" ||
+		res.Header.Get("Content-Length") != strconv.Itoa(len(body)) ||
+		!strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("Header:unexpected [%v]", res.Header)
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader():error=[%v]", err)
+	}
+	decoded, err := ioutil.ReadAll(gzipReader)
+	if err != nil || string(decoded) != indexHtml {
+		t.Errorf("Decoded body:unexpected [%s] error=[%v]", decoded, err)
+	}
+	// Get the range of the gzip stream.
+	req.Header.Set("Range", "bytes=5-20")
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Get [/] failed.")
+	}
+	partial, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent || !bytes.Equal(partial, body[5:21]) {
+		t.Errorf("Range:unexpected [%v] [%x]", res.StatusCode, partial)
+	}
+	// Get with "If-None-Match".
+	req.Header.Del("Range")
+	req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Get [/] failed.")
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected [%v] got [%v]", http.StatusNotModified, res.StatusCode)
+	}
+	// Get with the transparent decompression of the client.
+	res, err = http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatalf("Get [/] failed.")
+	}
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != indexHtml {
+		t.Errorf("Identity:unexpected [%s]", body)
+	}
+}
+
+func TestFileServerOptions(t *testing.T) {
+	// Build zgok file.
+	outPath := "file_server_options_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	builder.Build()
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	fileServer := zfs.FileServer("testdata",
+		WithCacheControl("dir/*", "public, max-age=31536000, immutable"),
+		WithCacheControl("foo", "no-cache"),
+		WithModTime(time.Time{}))
+	ts := httptest.NewServer(fileServer)
+	defer ts.Close()
+	// Check headers of "foo".
+	res, err := http.Get(ts.URL + "/foo")
+	if err != nil {
+		t.Fatalf("Get [/foo] failed.")
+	}
+	res.Body.Close()
+	if res.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf(`expected "no-cache" got "%v"`, res.Header.Get("Cache-Control"))
+	}
+	fooStat, _ := os.Stat("testdata/foo")
+	lastModified := fooStat.ModTime().UTC().Format(http.TimeFormat)
+	if res.Header.Get("Last-Modified") != lastModified {
+		t.Errorf(`expected "%v" got "%v"`, lastModified, res.Header.Get("Last-Modified"))
+	}
+	// Check headers of "dir/bar".
+	res, err = http.Get(ts.URL + "/dir/bar")
+	if err != nil {
+		t.Fatalf("Get [/dir/bar] failed.")
+	}
+	res.Body.Close()
+	if res.Header.Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("Cache-Control:unexpected [%v]", res.Header.Get("Cache-Control"))
+	}
+	// Check "If-Modified-Since".
+	req, _ := http.NewRequest("GET", ts.URL+"/dir/bar", nil)
+	req.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Get [/dir/bar] failed.")
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected [%v] got [%v]", http.StatusNotModified, res.StatusCode)
+	}
+}
+
+func TestFileServerSPAFallback(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	ioutil.WriteFile(fpath.Join(srcDir, "index.html"), []byte("<html>"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "app.js"), []byte("app"), 0644)
+	// Build zgok file.
+	outPath := "file_server_spa_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "public")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	if _, err = zfs.Open("public/users/1"); !os.IsNotExist(err) {
+		t.Errorf("Open():expected not exist error got [%v]", err)
+	}
+	tests := []struct {
+		server http.Handler
+		method string
+		path   string
+		accept string
+		status int
+		body   string
+	}{
+		{zfs.FileServer("public", WithSPAFallback("index.html")),
+			"GET", "/users/1", "text/html,*/*;q=0.8", 200, "<html>"},
+		{zfs.FileServer("public", WithSPAFallback("index.html")),
+			"GET", "/users/john.doe", "text/html", 200, "<html>"},
+		{zfs.FileServer("public", WithSPAFallback("index.html")),
+			"GET", "/users/1", "", 200, "<html>"},
+		{zfs.FileServer("public", WithSPAFallback("index.html")),
+			"GET", "/app.js", "*/*", 200, "app"},
+		{zfs.FileServer("public", WithSPAFallback("index.html")),
+			"GET", "/missing.js", "*/*", 404, ""},
+		{zfs.FileServer("public", WithSPAFallback("index.html")),
+			"POST", "/users/1", "text/html", 404, ""},
+		{zfs.FileServer("public", WithSPAFallback("index.html", AcceptsHTML)),
+			"GET", "/users/1", "", 404, ""},
+		{zfs.FileServer("public", WithSPAFallback("missing.html")),
+			"GET", "/users/1", "text/html", 404, ""},
+		{zfs.FileServer("public"),
+			"GET", "/users/1", "text/html", 404, ""},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		rec := httptest.NewRecorder()
+		test.server.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("[%d]:expected [%v] got [%v]", i, test.status, rec.Code)
+		}
+		if test.status == 200 && rec.Body.String() != test.body {
+			t.Errorf("[%d]:expected [%v] got [%v]", i, test.body, rec.Body.String())
+		}
+	}
+}
+
+func TestFileServerErrorPages(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	ioutil.WriteFile(fpath.Join(srcDir, "404.html"), []byte("<h1>Not Found</h1>"), 0644)
+	os.MkdirAll(fpath.Join(srcDir, "docs"), 0755)
+	ioutil.WriteFile(fpath.Join(srcDir, "docs", "a.txt"), []byte("a"), 0644)
+	// Build zgok file.
+	outPath := "file_server_error_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(srcDir, "public")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	var handledStatus int
+	errorHandler := func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		handledStatus = status
+		http.Error(w, "handled: "+err.Error(), http.StatusTeapot)
+	}
+	tests := []struct {
+		server http.Handler
+		path   string
+		status int
+		body   string
+	}{
+		{zfs.FileServer("public"), "/missing", 404, "404 page not found\n"},
+		{zfs.FileServer("public", WithErrorPage(404, "404.html")),
+			"/missing", 404, "<h1>Not Found</h1>"},
+		{zfs.FileServer("public", WithErrorPage(404, "missing.html")),
+			"/missing", 404, "404 page not found\n"},
+		{zfs.FileServer("public", WithErrorPage(404, "404.html"), WithDirectoryListing(true)),
+			"/docs/", 200, `<a href="a.txt">a.txt</a>`},
+		{zfs.FileServer("public", WithErrorPage(404, "404.html")),
+			"/docs/", 404, "<h1>Not Found</h1>"},
+		{zfs.FileServer("public", WithDirectoryListing(false)),
+			"/docs/a.txt", 200, "a"},
+		{zfs.FileServer("public", WithErrorHandler(errorHandler)),
+			"/missing", 418, "handled: "},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		rec := httptest.NewRecorder()
+		test.server.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("[%d]:expected [%v] got [%v]", i, test.status, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("[%d]:expected [%v] got [%v]", i, test.body, rec.Body.String())
+		}
+	}
+	if handledStatus != http.StatusNotFound {
+		t.Errorf("expected [%v] got [%v]", http.StatusNotFound, handledStatus)
+	}
+	// Intercept the error of the underlying handler.
+	server := zfs.FileServer("public", WithErrorPage(500, "404.html")).(*fileServer)
+	server.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal", http.StatusInternalServerError)
+	})
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/docs", nil))
+	if rec.Code != 500 || rec.Body.String() != "<h1>Not Found</h1>" ||
+		rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("Intercepted error:unexpected [%v] [%v] [%v]",
+			rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestFileServerImpliedDirectories(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	indexPath := fpath.Join(srcDir, "index.html")
+	ioutil.WriteFile(indexPath, []byte("<h1>sub</h1>"), 0644)
+	// Build zgok file with the directories implied by the file only.
+	outPath := "file_server_implied_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPathAs(indexPath, "web/sub/index.html")
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	if _, err = zfs.GetFile("web/sub"); err == nil {
+		t.Fatalf("Expected no directory entry in the payload.")
+	}
+	tests := []struct {
+		server http.Handler
+		path   string
+		status int
+		body   string
+	}{
+		{zfs.FileServer("web"), "/sub/", 200, "<h1>sub</h1>"},
+		{zfs.FileServer(""), "/web/sub/", 200, "<h1>sub</h1>"},
+		{zfs.FileServer("", WithDirectoryListing(true)), "/", 200, "web/"},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		rec := httptest.NewRecorder()
+		test.server.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("[%d]:expected [%v] got [%v]", i, test.status, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("[%d]:expected [%v] got [%v]", i, test.body, rec.Body.String())
+		}
+	}
+}
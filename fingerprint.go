@@ -0,0 +1,117 @@
+package zgok
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	MANIFEST_PATH      = "." + APP + "/asset-manifest.json" // Asset manifest outside the file tree of the payload.
+	FINGERPRINT_LENGTH = 8                                  // Length of the fingerprint in hex.
+
+	// Cache-Control of the fingerprinted paths unless a policy matches.
+	IMMUTABLE_CACHE_CONTROL = "public, max-age=31536000, immutable"
+)
+
+// Set glob patterns of the files to fingerprint.
+// Fingerprinted names (ex. "app.3f9a1c2b.js") are recorded in the asset
+// manifest by the logical paths, and the files keep the logical paths.
+func (z *Zipper) SetFingerprint(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	z.fpPatterns = patterns
+	return nil
+}
+
+// Record the fingerprinted path of the file matching the patterns.
+func (z *Zipper) addFingerprint(relPath string, content []byte) {
+	if !z.fpPaths[relPath] && !isExcluded(relPath, "", z.fpPatterns) {
+		return
+	}
+	z.manifest[relPath] = fingerprintPath(relPath, contentHash(content))
+}
+
+// Add the asset manifest to zip.
+func (z *Zipper) addManifest() error {
+	if len(z.manifest) == 0 {
+		return nil
+	}
+	content, err := json.MarshalIndent(z.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &zip.FileHeader{
+		Name:   MANIFEST_PATH,
+		Method: z.method,
+		Extra:  hashExtra(content),
+	}
+	header.SetMode(0644)
+	zipFile, err := z.createHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = zipFile.Write(content)
+	return err
+}
+
+// Get the fingerprinted path from the hex hash of the content.
+func fingerprintPath(relPath, hash string) string {
+	ext := path.Ext(relPath)
+	return strings.TrimSuffix(relPath, ext) + "." + hash[:FINGERPRINT_LENGTH] + ext
+}
+
+// Get the asset manifest of the file system.
+// Fingerprinted paths are mapped by the logical paths relative to the root
+// of the file system.
+func (zfs *zgokFileSystem) AssetManifest() (map[string]string, error) {
+	assets, err := zfs.allAssets()
+	if err != nil {
+		return nil, err
+	}
+	manifest := make(map[string]string)
+	// Get the paths under the root.
+	prefix := strings.TrimPrefix(zfs.rootPath+"/", APP+"/")
+	for logical, fingerprinted := range assets {
+		if strings.HasPrefix(logical, prefix) && strings.HasPrefix(fingerprinted, prefix) {
+			manifest[strings.TrimPrefix(logical, prefix)] = strings.TrimPrefix(fingerprinted, prefix)
+		}
+	}
+	return manifest, nil
+}
+
+// Get the whole asset manifest of the payload.
+func (zfs *zgokFileSystem) allAssets() (map[string]string, error) {
+	assets := make(map[string]string)
+	file, exists := zfs.fileMap[MANIFEST_PATH]
+	if !exists {
+		return assets, nil
+	}
+	if err := json.Unmarshal(file.Bytes(), &assets); err != nil {
+		return nil, fmt.Errorf("invalid asset manifest: %v", err)
+	}
+	return assets, nil
+}
+
+// Get the function resolving the logical paths to the URLs of the
+// fingerprinted paths. The paths without fingerprints are resolved as is.
+// Usable in "html/template". (ex. template.FuncMap{"asset": zfs.AssetFunc("/assets/")})
+func (zfs *zgokFileSystem) AssetFunc(urlPrefix string) func(name string) (string, error) {
+	manifest, manifestErr := zfs.AssetManifest()
+	return func(name string) (string, error) {
+		if manifestErr != nil {
+			return "", manifestErr
+		}
+		logical := path.Clean("/" + name)[1:]
+		if fingerprinted, exists := manifest[logical]; exists {
+			return urlPrefix + fingerprinted, nil
+		}
+		if _, err := zfs.GetFile(logical); err != nil {
+			return "", fmt.Errorf("asset not found [%s]", name)
+		}
+		return urlPrefix + logical, nil
+	}
+}
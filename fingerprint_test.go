@@ -0,0 +1,172 @@
+package zgok
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fpath "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	// Build zgok file.
+	outPath := "fingerprint_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	err := builder.SetFingerprint("testdata/dir/bar")
+	if err != nil {
+		t.Fatalf("SetFingerprint():error=[%v]", err)
+	}
+	builder.SetOutPath(outPath)
+	err = builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	// Verify the manifest.
+	expected := fingerprintPath("testdata/dir/bar", contentHash([]byte("bar")))
+	manifest, err := zfs.AssetManifest()
+	if err != nil || len(manifest) != 1 || manifest["testdata/dir/bar"] != expected {
+		t.Errorf("AssetManifest(): expected [%v] got [%v] error=[%v]", expected, manifest, err)
+	}
+	subFs, _ := zfs.SubFileSystem("testdata")
+	if manifest, _ = subFs.AssetManifest(); manifest["dir/bar"] != expected[len("testdata/"):] {
+		t.Errorf("AssetManifest(): got [%v]", manifest)
+	}
+	// Resolve the assets in the template.
+	tmpl := template.Must(template.New("page").Funcs(template.FuncMap{
+		"asset": subFs.AssetFunc("/static/"),
+	}).Parse(`{{asset "dir/bar"}} {{asset "foo"}}`))
+	var page bytes.Buffer
+	err = tmpl.Execute(&page, nil)
+	if err != nil {
+		t.Errorf("Execute():error=[%v]", err)
+	}
+	expectedPage := "/static/" + expected[len("testdata/"):] + " /static/foo"
+	if page.String() != expectedPage {
+		t.Errorf(`expected "%v" got "%v"`, expectedPage, page.String())
+	}
+	if _, err = subFs.AssetFunc("/")("missing"); err == nil {
+		t.Errorf("Expected error on missing asset.")
+	}
+	// Serve both the logical and the fingerprinted paths.
+	ts := httptest.NewServer(zfs.FileServer("testdata"))
+	defer ts.Close()
+	for urlPath, cacheControl := range map[string]string{
+		"/dir/bar":                        "",
+		"/" + expected[len("testdata/"):]: IMMUTABLE_CACHE_CONTROL,
+	} {
+		res, err := http.Get(ts.URL + urlPath)
+		if err != nil {
+			t.Fatalf("Get [%v] failed.", urlPath)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || string(content) != "bar" {
+			t.Errorf(`[%v] expected "bar" got [%v] "%v"`, urlPath, res.StatusCode, string(content))
+		}
+		if res.Header.Get("Cache-Control") != cacheControl {
+			t.Errorf(`[%v] expected "%v" got "%v"`, urlPath, cacheControl, res.Header.Get("Cache-Control"))
+		}
+	}
+	// Fingerprints are updated on saving the modified content.
+	wfs, err := NewWritableFileSystem(zfs)
+	if err != nil {
+		t.Fatalf("NewWritableFileSystem():error=[%v]", err)
+	}
+	wfs.WriteFile("testdata/dir/bar", []byte("new bar"), 0644)
+	savedPath := "fingerprint_saved_test.out"
+	err = wfs.SavePack(savedPath)
+	if err != nil {
+		t.Fatalf("SavePack():error=[%v]", err)
+	}
+	saved, err := RestoreFileSystem(savedPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	expected = fingerprintPath("testdata/dir/bar", contentHash([]byte("new bar")))
+	if manifest, _ = saved.AssetManifest(); manifest["testdata/dir/bar"] != expected {
+		t.Errorf("AssetManifest(): expected [%v] got [%v]", expected, manifest)
+	}
+}
+
+func TestFingerprintReservedManifest(t *testing.T) {
+	// Prepare source files with the manifest of the other build tool.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	toolManifest := `{"files": {"main.js": "/static/main.js"}}`
+	ioutil.WriteFile(fpath.Join(srcDir, "asset-manifest.json"), []byte(toolManifest), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "main.js"), []byte("main"), 0644)
+	// Build zgok pack.
+	outPath := "fingerprint_reserved_test.out"
+	builder := NewZgokBuilder()
+	builder.AddZipPathAs(srcDir, ".")
+	builder.SetFingerprint("*.js")
+	builder.SetOutPath(outPath)
+	err = builder.BuildPack()
+	if err != nil {
+		t.Fatalf("BuildPack():error=[%v]", err)
+	}
+	zfs, err := RestorePack(outPath)
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	// The file of the other build tool is kept as is.
+	expected := "asset-manifest.json,main.js"
+	if strings.Join(zfs.Paths(), ",") != expected {
+		t.Errorf("Paths(): expected [%v] got [%v]", expected, zfs.Paths())
+	}
+	if content, _ := zfs.ReadFileString("asset-manifest.json"); content != toolManifest {
+		t.Errorf(`expected "%v" got "%v"`, toolManifest, content)
+	}
+	manifest, err := zfs.AssetManifest()
+	if err != nil || len(manifest) != 1 || manifest["main.js"] == "" {
+		t.Errorf("AssetManifest(): got [%v] error=[%v]", manifest, err)
+	}
+	// Kept on saving again.
+	wfs, err := NewWritableFileSystem(zfs)
+	if err != nil {
+		t.Fatalf("NewWritableFileSystem():error=[%v]", err)
+	}
+	savedPath := "fingerprint_reserved_saved_test.out"
+	err = wfs.SavePack(savedPath)
+	if err != nil {
+		t.Fatalf("SavePack():error=[%v]", err)
+	}
+	saved, err := RestorePack(savedPath)
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	if content, _ := saved.ReadFileString("asset-manifest.json"); content != toolManifest {
+		t.Errorf(`expected "%v" got "%v"`, toolManifest, content)
+	}
+	if savedManifest, _ := saved.AssetManifest(); savedManifest["main.js"] != manifest["main.js"] {
+		t.Errorf("AssetManifest(): expected [%v] got [%v]", manifest, savedManifest)
+	}
+	// Invalid manifest is an error.
+	broken := NewZgokFile()
+	broken.SetPath(MANIFEST_PATH)
+	broken.SetBytes([]byte("{"))
+	zfs.AddFile(broken)
+	if _, err = zfs.AssetManifest(); err == nil {
+		t.Errorf("Expected error on invalid manifest.")
+	}
+	if _, err = zfs.AssetFunc("/")("main.js"); err == nil {
+		t.Errorf("Expected error on invalid manifest.")
+	}
+	if _, err = wfs.AssetManifest(); err != nil {
+		t.Errorf("AssetManifest():error=[%v]", err)
+	}
+}
@@ -0,0 +1,67 @@
+//go:build go1.18
+// +build go1.18
+
+package zgok
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzUnzip(f *testing.F) {
+	// Add seed corpus.
+	zipper := NewZipper()
+	zipper.Add("testdata/foo")
+	zipper.Add("testdata/dir")
+	zipper.SetMetadata(map[string]string{"version": "1.0.0"})
+	zipper.Close()
+	zipBytes, _ := zipper.Bytes()
+	f.Add(zipBytes)
+	f.Add(createZipBytes(f, "zgok/foo", "zgok/dir/", "zgok/dir/bar"))
+	f.Add(createZipBytes(f, "zgok/../evil", "/abs", `zgok\win`))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		zfs, err := NewUnzipper(&data).Unzip()
+		if err != nil {
+			return
+		}
+		// All paths in the restored file system must be safe.
+		for _, path := range zfs.Paths() {
+			if _, err := cleanEntryName(APP + "/" + path); err != nil {
+				t.Errorf("Unsafe path [%q] restored: %v", path, err)
+			}
+			if strings.HasPrefix(path, "/") {
+				t.Errorf("Absolute path [%q] restored.", path)
+			}
+		}
+	})
+}
+
+func FuzzRestoreSignature(f *testing.F) {
+	// Add seed corpus.
+	signature := NewSignature()
+	signature.SetExeSize(1234)
+	signature.SetZipSize(5678)
+	sigBytes, _ := signature.Dump()
+	f.Add(sigBytes)
+	f.Add(make([]byte, SIGNATURE_BYTE_SIZE))
+	f.Add([]byte("zgok"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		restored, err := RestoreSignature(data)
+		if err != nil {
+			return
+		}
+		if restored.ExeSize() < 0 || restored.ZipSize() <= 0 {
+			t.Errorf("Invalid sizes restored: %v", restored)
+		}
+		// Dump and restore again.
+		dumped, err := restored.Dump()
+		if err != nil {
+			t.Fatalf("Dump():error=[%v]", err)
+		}
+		again, err := RestoreSignature(dumped)
+		if err != nil || again.String() != restored.String() {
+			t.Errorf("Restored signature differs: [%v] [%v]", restored, again)
+		}
+	})
+}
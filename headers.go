@@ -0,0 +1,153 @@
+package zgok
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Header for the paths matching the glob pattern.
+type staticHeader struct {
+	pattern string // Glob pattern of the paths.
+	name    string // Name of the header.
+	value   string // Value of the header.
+}
+
+// CORS policy.
+type CORS struct {
+	AllowOrigins     []string // Allowed origins. ("*" for any origin)
+	AllowMethods     []string // Allowed methods. (GET and HEAD by default)
+	AllowHeaders     []string // Allowed request headers.
+	ExposeHeaders    []string // Response headers exposed to the clients.
+	AllowCredentials bool     // Are the credentials allowed? (Only for the listed origins)
+	MaxAge           int      // Seconds to cache the preflight response. (0 for no header)
+}
+
+// Set the header for the paths matching the glob pattern.
+// Patterns are matched against the path and the base name of the file served,
+// or of the request for the responses without a file. (ex. errors and listings)
+// All the matching headers are set on every response including the errors.
+// (ex. WithHeader("*", "X-Content-Type-Options", "nosniff"))
+func WithHeader(pattern, name, value string) ServerOption {
+	return func(s *fileServer) {
+		s.headers = append(s.headers, staticHeader{pattern: pattern, name: name, value: value})
+	}
+}
+
+// Set the CORS policy.
+// Preflight requests from the allowed origins are responded with 204, and
+// the others with 403.
+func WithCORS(cors CORS) ServerOption {
+	return func(s *fileServer) {
+		if len(cors.AllowMethods) == 0 {
+			cors.AllowMethods = []string{"GET", "HEAD"}
+		}
+		s.cors = &cors
+	}
+}
+
+// Set the static headers of the path.
+func (s *fileServer) setHeaders(w http.ResponseWriter, name string) {
+	for _, header := range s.headers {
+		if isExcluded(name, "", []string{header.pattern}) {
+			w.Header().Set(header.name, header.value)
+		}
+	}
+}
+
+// Set the CORS headers, and respond to the preflight request.
+// Returns true if the request is responded.
+func (s *fileServer) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	if s.cors == nil {
+		return false
+	}
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	isPreflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+	listed := origin != "" && s.cors.listsOrigin(origin)
+	if origin == "" || (!listed && !containsString(s.cors.AllowOrigins, "*")) {
+		if isPreflight {
+			s.serveError(w, r, http.StatusForbidden, errors.New("origin not allowed"))
+		}
+		return isPreflight
+	}
+	// Set the headers of the allowed origin.
+	// Origins allowed by the wildcard are never echoed with the credentials.
+	if listed {
+		header.Set("Access-Control-Allow-Origin", origin)
+		if s.cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	} else {
+		header.Set("Access-Control-Allow-Origin", "*")
+	}
+	if !isPreflight {
+		if len(s.cors.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(s.cors.ExposeHeaders, ", "))
+		}
+		return false
+	}
+	// Respond to the preflight request.
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if !s.cors.allowsRequest(r) {
+		header.Del("Access-Control-Allow-Origin")
+		header.Del("Access-Control-Allow-Credentials")
+		s.serveError(w, r, http.StatusForbidden, errors.New("method or headers not allowed"))
+		return true
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(s.cors.AllowMethods, ", "))
+	if len(s.cors.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(s.cors.AllowHeaders, ", "))
+	}
+	if s.cors.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(s.cors.MaxAge))
+	}
+	s.setHeaders(w, path.Clean("/" + r.URL.Path)[1:])
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// Check if the origin is listed explicitly.
+func (cors *CORS) listsOrigin(origin string) bool {
+	for _, allowed := range cors.AllowOrigins {
+		if allowed != "*" && strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the method and the headers of the preflight request are allowed.
+func (cors *CORS) allowsRequest(r *http.Request) bool {
+	if !containsString(cors.AllowMethods, r.Header.Get("Access-Control-Request-Method")) {
+		return false
+	}
+	for _, name := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		allowed := false
+		for _, allowedName := range cors.AllowHeaders {
+			allowed = allowed || strings.EqualFold(allowedName, name)
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if the values contain the value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
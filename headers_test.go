@@ -0,0 +1,186 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fpath "path/filepath"
+	"testing"
+)
+
+func TestFileServerHeaders(t *testing.T) {
+	// Build zgok file.
+	outPath := "headers_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	ts := httptest.NewServer(zfs.FileServer("testdata",
+		WithHeader("*", "X-Content-Type-Options", "nosniff"),
+		WithHeader("dir/*", "X-Frame-Options", "DENY"),
+		WithCORS(CORS{
+			AllowOrigins:  []string{"https://example.com"},
+			AllowHeaders:  []string{"X-Requested-With"},
+			ExposeHeaders: []string{"ETag"},
+			MaxAge:        600,
+		})))
+	defer ts.Close()
+	do := func(method, urlPath string, headers map[string]string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+urlPath, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s [%s] failed.", method, urlPath)
+		}
+		res.Body.Close()
+		return res
+	}
+	check := func(res *http.Response, name, expected string) {
+		if res.Header.Get(name) != expected {
+			t.Errorf(`[%v] %v: expected "%v" got "%v"`, res.Request.URL.Path, name, expected, res.Header.Get(name))
+		}
+	}
+	// Static headers by the patterns, also on the errors.
+	res := do("GET", "/foo", nil)
+	check(res, "X-Content-Type-Options", "nosniff")
+	check(res, "X-Frame-Options", "")
+	check(res, "Access-Control-Allow-Origin", "")
+	res = do("GET", "/dir/bar", nil)
+	check(res, "X-Frame-Options", "DENY")
+	res = do("GET", "/missing", nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected [404] got [%v]", res.StatusCode)
+	}
+	check(res, "X-Content-Type-Options", "nosniff")
+	// CORS headers of the allowed origin.
+	res = do("GET", "/foo", map[string]string{"Origin": "https://example.com"})
+	check(res, "Access-Control-Allow-Origin", "https://example.com")
+	check(res, "Access-Control-Expose-Headers", "ETag")
+	check(res, "Vary", "Origin")
+	res = do("GET", "/foo", map[string]string{"Origin": "https://evil.example"})
+	check(res, "Access-Control-Allow-Origin", "")
+	// Preflight requests.
+	preflights := []struct {
+		origin  string
+		method  string
+		headers string
+		status  int
+	}{
+		{"https://example.com", "GET", "x-requested-with", http.StatusNoContent},
+		{"https://evil.example", "GET", "", http.StatusForbidden},
+		{"https://example.com", "PUT", "", http.StatusForbidden},
+		{"https://example.com", "GET", "Authorization", http.StatusForbidden},
+	}
+	for _, preflight := range preflights {
+		res = do("OPTIONS", "/foo", map[string]string{
+			"Origin":                         preflight.origin,
+			"Access-Control-Request-Method":  preflight.method,
+			"Access-Control-Request-Headers": preflight.headers,
+		})
+		if res.StatusCode != preflight.status {
+			t.Errorf("[%v] expected [%v] got [%v]", preflight, preflight.status, res.StatusCode)
+		}
+		if preflight.status == http.StatusNoContent {
+			check(res, "Access-Control-Allow-Origin", preflight.origin)
+			check(res, "Access-Control-Allow-Methods", "GET, HEAD")
+			check(res, "Access-Control-Allow-Headers", "X-Requested-With")
+			check(res, "Access-Control-Max-Age", "600")
+		} else {
+			check(res, "Access-Control-Allow-Origin", "")
+		}
+	}
+	// The wildcard never echoes the origin with the credentials.
+	wildcard := httptest.NewServer(zfs.FileServer("testdata",
+		WithCORS(CORS{
+			AllowOrigins:     []string{"*", "https://example.com"},
+			AllowCredentials: true,
+		})))
+	defer wildcard.Close()
+	ts.URL = wildcard.URL
+	res = do("GET", "/foo", map[string]string{"Origin": "https://evil.example"})
+	check(res, "Access-Control-Allow-Origin", "*")
+	check(res, "Access-Control-Allow-Credentials", "")
+	res = do("OPTIONS", "/foo", map[string]string{
+		"Origin":                        "https://evil.example",
+		"Access-Control-Request-Method": "GET",
+	})
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected [204] got [%v]", res.StatusCode)
+	}
+	check(res, "Access-Control-Allow-Origin", "*")
+	check(res, "Access-Control-Allow-Credentials", "")
+	res = do("GET", "/foo", map[string]string{"Origin": "https://example.com"})
+	check(res, "Access-Control-Allow-Origin", "https://example.com")
+	check(res, "Access-Control-Allow-Credentials", "true")
+}
+
+func TestFileServerHeadersOfServedFile(t *testing.T) {
+	// Prepare source files.
+	srcDir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(srcDir)
+	ioutil.WriteFile(fpath.Join(srcDir, "index.html"), []byte("<p>index</p>"), 0644)
+	ioutil.WriteFile(fpath.Join(srcDir, "app.js"), []byte("app"), 0644)
+	// Build zgok pack.
+	outPath := "headers_served_test.out"
+	builder := NewZgokBuilder()
+	builder.AddZipPathAs(srcDir, "public")
+	builder.SetOutPath(outPath)
+	err = builder.BuildPack()
+	if err != nil {
+		t.Fatalf("BuildPack():error=[%v]", err)
+	}
+	zfs, err := RestorePack(outPath)
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	ts := httptest.NewServer(zfs.FileServer("public",
+		WithHeader("*.html", "Content-Security-Policy", "default-src 'self'"),
+		WithSPAFallback("index.html")))
+	defer ts.Close()
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	// Headers match the file served, not the request path.
+	tests := []struct {
+		path   string
+		accept string
+		status int
+		csp    string
+	}{
+		{"/", "text/html", http.StatusOK, "default-src 'self'"},
+		{"/users/1", "text/html", http.StatusOK, "default-src 'self'"},
+		{"/app.js", "", http.StatusOK, ""},
+		{"/index.html", "text/html", http.StatusMovedPermanently, ""},
+		{"/missing.html", "", http.StatusNotFound, "default-src 'self'"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", ts.URL+test.path, nil)
+		req.Header.Set("Accept", test.accept)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Get [%s] failed.", test.path)
+		}
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("[%v] expected [%v] got [%v]", test.path, test.status, res.StatusCode)
+		}
+		if csp := res.Header.Get("Content-Security-Policy"); csp != test.csp {
+			t.Errorf(`[%v] expected "%v" got "%v"`, test.path, test.csp, csp)
+		}
+	}
+}
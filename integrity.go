@@ -0,0 +1,63 @@
+package zgok
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+)
+
+const (
+	SRI_SHA256 = "sha256" // SRI algorithm of SHA-256.
+	SRI_SHA384 = "sha384" // SRI algorithm of SHA-384.
+	SRI_SHA512 = "sha512" // SRI algorithm of SHA-512.
+
+	SRI_CROSSORIGIN = "anonymous" // Crossorigin attribute of the tags with integrity.
+)
+
+// Get the Subresource Integrity string of the file. (ex. "sha384-...")
+// Digests recorded at build time are used.
+func (zfs *zgokFileSystem) Integrity(path, algorithm string) (string, error) {
+	file, err := zfs.GetFile(path)
+	if err != nil {
+		return "", err
+	}
+	if file.FileInfo() != nil && file.FileInfo().IsDir() {
+		return "", fmt.Errorf("is a directory")
+	}
+	digest := file.Digest(algorithm)
+	if digest == nil {
+		return "", fmt.Errorf("unknown integrity algorithm [%s]", algorithm)
+	}
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(digest), nil
+}
+
+// Get the template functions of the integrity.
+// "integrity" returns the integrity string, and "script" and "stylesheet"
+// return the complete tags with the integrity and crossorigin attributes.
+// URLs are resolved by the asset function. (ex. {{script "js/app.js"}})
+func (zfs *zgokFileSystem) IntegrityFuncs(urlPrefix, algorithm string) template.FuncMap {
+	asset := zfs.AssetFunc(urlPrefix)
+	integrity := func(name string) (string, error) {
+		return zfs.Integrity(name, algorithm)
+	}
+	// Create the tag from the format with the URL and the integrity.
+	tag := func(format string) func(name string) (template.HTML, error) {
+		return func(name string) (template.HTML, error) {
+			url, err := asset(name)
+			if err != nil {
+				return "", err
+			}
+			sri, err := integrity(name)
+			if err != nil {
+				return "", err
+			}
+			return template.HTML(fmt.Sprintf(format, template.HTMLEscapeString(url),
+				sri, SRI_CROSSORIGIN)), nil
+		}
+	}
+	return template.FuncMap{
+		"integrity":  integrity,
+		"script":     tag(`<script src="%s" integrity="%s" crossorigin="%s"></script>`),
+		"stylesheet": tag(`<link rel="stylesheet" href="%s" integrity="%s" crossorigin="%s">`),
+	}
+}
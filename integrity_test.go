@@ -0,0 +1,98 @@
+package zgok
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"html/template"
+	"testing"
+)
+
+func TestIntegrity(t *testing.T) {
+	// Build zgok file.
+	outPath := "integrity_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	// Digests are recorded in the payload.
+	file, _ := zfs.GetFile("testdata/foo")
+	if zf := file.(*zgokFile); zf.sha384 == nil || zf.sha512 == nil {
+		t.Errorf("Expected digests recorded in the payload.")
+	}
+	sum256 := sha256.Sum256([]byte("foo"))
+	sum384 := sha512.Sum384([]byte("foo"))
+	sum512 := sha512.Sum512([]byte("foo"))
+	for algorithm, sum := range map[string][]byte{
+		SRI_SHA256: sum256[:],
+		SRI_SHA384: sum384[:],
+		SRI_SHA512: sum512[:],
+	} {
+		expected := algorithm + "-" + base64.StdEncoding.EncodeToString(sum)
+		sri, err := zfs.Integrity("testdata/foo", algorithm)
+		if err != nil || sri != expected {
+			t.Errorf("Integrity(): expected [%v] got [%v] error=[%v]", expected, sri, err)
+		}
+	}
+	// Verify errors.
+	if _, err = zfs.Integrity("testdata/foo", "md5"); err == nil {
+		t.Errorf("Expected error on unknown algorithm.")
+	}
+	if _, err = zfs.Integrity("testdata/dir", SRI_SHA384); err == nil {
+		t.Errorf("Expected error on directory.")
+	}
+	if _, err = zfs.Integrity("missing", SRI_SHA384); err == nil {
+		t.Errorf("Expected error on missing file.")
+	}
+	// Emit the tags in the template.
+	subFs, _ := zfs.SubFileSystem("testdata")
+	tmpl := template.Must(template.New("page").
+		Funcs(subFs.IntegrityFuncs("/static/", SRI_SHA384)).
+		Parse(`{{script "foo"}}{{stylesheet "dir/bar"}}`))
+	var page bytes.Buffer
+	err = tmpl.Execute(&page, nil)
+	if err != nil {
+		t.Errorf("Execute():error=[%v]", err)
+	}
+	sri, _ := subFs.Integrity("dir/bar", SRI_SHA384)
+	expected := `<script src="/static/foo" integrity="sha384-` +
+		base64.StdEncoding.EncodeToString(sum384[:]) + `" crossorigin="anonymous"></script>` +
+		`<link rel="stylesheet" href="/static/dir/bar" integrity="` + sri + `" crossorigin="anonymous">`
+	if page.String() != expected {
+		t.Errorf(`expected "%v" got "%v"`, expected, page.String())
+	}
+}
+
+func TestFindExtra(t *testing.T) {
+	content := []byte("foo")
+	extra := append(hashExtra(content), contentTypeExtra("text/plain")...)
+	sum256 := sha256.Sum256(content)
+	if !bytes.Equal(parseHashExtra(extra), sum256[:]) {
+		t.Errorf("parseHashExtra(): got [%x]", parseHashExtra(extra))
+	}
+	sum384 := sha512.Sum384(content)
+	sha384, sha512 := parseIntegrityExtra(extra)
+	if !bytes.Equal(sha384, sum384[:]) || len(sha512) != 64 {
+		t.Errorf("parseIntegrityExtra(): got [%x] [%x]", sha384, sha512)
+	}
+	if ctype := parseContentTypeExtra(extra); ctype != "text/plain" {
+		t.Errorf(`parseContentTypeExtra(): expected "text/plain" got "%v"`, ctype)
+	}
+	// Truncated extra field holds nothing.
+	if data := findExtra(extra[:len(extra)-1], CONTENT_TYPE_EXTRA_ID); data != nil {
+		t.Errorf("findExtra(): expected nil got [%x]", data)
+	}
+	if data := findExtra(extra, 0xffff); data != nil {
+		t.Errorf("findExtra(): expected nil got [%x]", data)
+	}
+}
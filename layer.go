@@ -0,0 +1,225 @@
+package zgok
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	WHITEOUT_PREFIX = ".wh."         // Prefix of the whiteout entry hiding a lower file.
+	WHITEOUT_OPAQUE = ".wh..wh..opq" // Whiteout entry hiding all the lower files in the directory.
+)
+
+// Create a layered file system.
+// Layers are given from the bottom to the top, and the files in the higher
+// layers shadow the files with the same paths in the lower layers.
+// The signature and the metadata are taken from the bottom layer.
+func NewLayeredFileSystem(layers ...FileSystem) (FileSystem, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no layers")
+	}
+	zfs := NewFileSystem().(*zgokFileSystem)
+	zfs.topLayer = -1
+	for _, layer := range layers {
+		zfs.Mount(layer)
+	}
+	zfs.SetSignature(layers[0].Signature())
+	zfs.SetMetadata(layers[0].Metadata())
+	return zfs, nil
+}
+
+// Load the files in the directory into a file system.
+// The file system is a snapshot of the directory at the time of loading.
+func LoadDirFileSystem(dirPath string) (FileSystem, error) {
+	zipper := NewZipper()
+	zipper.SetMethod(zip.Store)
+	err := zipper.AddAs(dirPath, ".")
+	if err != nil {
+		zipper.Close()
+		return nil, err
+	}
+	err = zipper.Close()
+	if err != nil {
+		return nil, err
+	}
+	zipBytes, err := zipper.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return NewUnzipper(&zipBytes).Unzip()
+}
+
+// Mount the layer on the top of the file system.
+// Files in the layer shadow the files with the same paths, and the
+// whiteout entries in the layer hide the files in the lower layers.
+func (zfs *zgokFileSystem) Mount(layer FileSystem) {
+	zfs.topLayer++
+	if zfs.origins == nil {
+		zfs.origins = make(map[string]int)
+	}
+	entries := layerEntries(layer)
+	// Remove the files hidden by the whiteout entries.
+	for relPath := range entries {
+		dir, name := path.Split(relPath)
+		if !strings.HasPrefix(name, WHITEOUT_PREFIX) {
+			continue
+		}
+		dirKey := path.Join(zfs.rootPath, dir)
+		if name == WHITEOUT_OPAQUE {
+			zfs.removeTree(dirKey, false)
+		} else {
+			zfs.removeTree(path.Join(dirKey, strings.TrimPrefix(name, WHITEOUT_PREFIX)), true)
+		}
+	}
+	// Add the files in the layer.
+	for relPath, file := range entries {
+		if strings.HasPrefix(path.Base(relPath), WHITEOUT_PREFIX) {
+			continue
+		}
+		key := path.Join(zfs.rootPath, relPath)
+		// A file hides the lower directory with the same path.
+		if file.FileInfo() != nil && !file.FileInfo().IsDir() {
+			zfs.removeTree(key, false)
+		}
+		zfs.fileMap[key] = file
+		zfs.origins[key] = zfs.topLayer
+	}
+	zfs.mergeManifest(layer)
+}
+
+// Merge the asset manifest of the layer mounted on the top.
+// Entries of the files hidden or replaced by the layer are dropped.
+func (zfs *zgokFileSystem) mergeManifest(layer FileSystem) {
+	// Keep the invalid manifest to report the error.
+	assets, err := zfs.allAssets()
+	if err != nil {
+		return
+	}
+	layerAssets := make(map[string]string)
+	if zl, ok := zgokFileSystemOf(layer); ok {
+		layerAssets, err = zl.AssetManifest()
+		if err != nil {
+			zfs.fileMap[MANIFEST_PATH] = zl.fileMap[MANIFEST_PATH]
+			return
+		}
+	}
+	for logical := range assets {
+		key := path.Join(APP, logical)
+		if _, exists := zfs.fileMap[key]; !exists || zfs.origins[key] == zfs.topLayer {
+			delete(assets, logical)
+		}
+	}
+	// Paths in the layer are relative to the root.
+	prefix := strings.TrimPrefix(zfs.rootPath+"/", APP+"/")
+	for logical, fingerprinted := range layerAssets {
+		assets[prefix+logical] = prefix + fingerprinted
+	}
+	if len(assets) == 0 {
+		delete(zfs.fileMap, MANIFEST_PATH)
+		return
+	}
+	content, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return
+	}
+	manifest := NewZgokFile()
+	manifest.SetPath(MANIFEST_PATH)
+	manifest.SetFileInfo(zgokFileInfo{
+		name:    path.Base(MANIFEST_PATH),
+		size:    int64(len(content)),
+		mode:    0644,
+		modTime: time.Now(),
+	})
+	manifest.SetBytes(content)
+	zfs.fileMap[MANIFEST_PATH] = manifest
+}
+
+// File system backed by the zgok file system.
+type zgokBacked interface {
+	unwrap() *zgokFileSystem
+}
+
+// Get the zgok file system itself.
+// Implements [zgokBacked.unwrap]
+func (zfs *zgokFileSystem) unwrap() *zgokFileSystem {
+	return zfs
+}
+
+// Get the zgok file system backing the file system. (ex. writable file system)
+func zgokFileSystemOf(fs FileSystem) (*zgokFileSystem, bool) {
+	if backed, ok := fs.(zgokBacked); ok {
+		return backed.unwrap(), true
+	}
+	return nil, false
+}
+
+// Get the index of the layer serving the file. (0 for the bottom layer)
+func (zfs *zgokFileSystem) LayerOf(path string) (int, error) {
+	key, _, err := zfs.getFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return zfs.origins[key], nil
+}
+
+// Remove the entries under the key.
+// The entry of the key itself is also removed if self is true.
+func (zfs *zgokFileSystem) removeTree(key string, self bool) {
+	prefix := key + "/"
+	for fileKey := range zfs.fileMap {
+		if strings.HasPrefix(fileKey, prefix) || (self && fileKey == key) {
+			delete(zfs.fileMap, fileKey)
+			delete(zfs.origins, fileKey)
+		}
+	}
+}
+
+// Entry of the file system.
+type fsEntry struct {
+	relPath string // Relative path from the root.
+	file    File   // File.
+}
+
+// Get all the entries in the file system sorted by the relative paths.
+func fileSystemEntries(fs FileSystem) []fsEntry {
+	entryMap := layerEntries(fs)
+	relPaths := make([]string, 0, len(entryMap))
+	for relPath := range entryMap {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+	entries := make([]fsEntry, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		entries = append(entries, fsEntry{relPath: relPath, file: entryMap[relPath]})
+	}
+	return entries
+}
+
+// Get all the entries in the layer by the relative paths.
+func layerEntries(layer FileSystem) map[string]File {
+	entries := make(map[string]File)
+	// Get all the entries including directories and symlinks.
+	if zl, ok := zgokFileSystemOf(layer); ok {
+		prefix := zl.rootPath + "/"
+		for key, file := range zl.fileMap {
+			if strings.HasPrefix(key, prefix) {
+				entries[strings.TrimPrefix(key, prefix)] = file
+			}
+		}
+		return entries
+	}
+	// Get files of other implementations.
+	for _, relPath := range layer.Paths() {
+		file, err := layer.GetFile(relPath)
+		if err == nil {
+			entries[filepath.ToSlash(relPath)] = file
+		}
+	}
+	return entries
+}
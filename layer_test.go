@@ -0,0 +1,154 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLayeredFileSystem(t *testing.T) {
+	// Create base layer.
+	zipper := NewZipper()
+	zipper.Add("testdata/foo")
+	zipper.Add("testdata/dir")
+	zipper.Close()
+	zipBytes, _ := zipper.Bytes()
+	base, err := NewUnzipper(&zipBytes).Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	// Create override layer on disk.
+	dir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(fpath.Join(dir, "testdata", "dir"), 0755)
+	ioutil.WriteFile(fpath.Join(dir, "testdata", "foo"), []byte("new foo"), 0644)
+	ioutil.WriteFile(fpath.Join(dir, "testdata", "dir", ".wh.baz"), []byte{}, 0644)
+	ioutil.WriteFile(fpath.Join(dir, "testdata", "qux"), []byte("qux"), 0644)
+	override, err := LoadDirFileSystem(dir)
+	if err != nil {
+		t.Fatalf("LoadDirFileSystem():error=[%v]", err)
+	}
+	// Stack layers.
+	zfs, err := NewLayeredFileSystem(base, override)
+	if err != nil {
+		t.Fatalf("NewLayeredFileSystem():error=[%v]", err)
+	}
+	// Verify merged paths.
+	expected := "testdata/dir/bar,testdata/foo,testdata/qux"
+	if strings.Join(zfs.Paths(), ",") != expected {
+		t.Errorf("Paths(): expected [%v] got [%v]", expected, zfs.Paths())
+	}
+	// Verify shadowed file.
+	fooStr, _ := zfs.ReadFileString("testdata/foo")
+	if fooStr != "new foo" {
+		t.Errorf(`expected "new foo" got "%v"`, fooStr)
+	}
+	// Verify layers serving the files.
+	tests := map[string]int{
+		"testdata/foo":     1,
+		"testdata/qux":     1,
+		"testdata/dir/bar": 0,
+	}
+	for path, expected := range tests {
+		layer, err := zfs.LayerOf(path)
+		if err != nil || layer != expected {
+			t.Errorf("LayerOf(%s): expected [%v] got [%v] error [%v]", path, expected, layer, err)
+		}
+	}
+	if _, err = zfs.LayerOf("testdata/dir/baz"); err == nil {
+		t.Errorf("Expected error on whiteout file.")
+	}
+	// Hide whole directory with opaque whiteout.
+	hotfix := NewFileSystem()
+	whiteout := NewZgokFile()
+	whiteout.SetPath("zgok/testdata/dir/" + WHITEOUT_OPAQUE)
+	whiteout.SetFileInfo(zgokFileInfo{name: WHITEOUT_OPAQUE})
+	hotfix.AddFile(whiteout)
+	zfs.Mount(hotfix)
+	expected = "testdata/foo,testdata/qux"
+	if strings.Join(zfs.Paths(), ",") != expected {
+		t.Errorf("Paths(): expected [%v] got [%v]", expected, zfs.Paths())
+	}
+	// Mount on sub file system does not change the parent.
+	subFs, _ := zfs.SubFileSystem("testdata")
+	patch := NewFileSystem()
+	qux := NewZgokFile()
+	qux.SetPath("zgok/qux")
+	qux.SetFileInfo(zgokFileInfo{name: "qux"})
+	qux.SetBytes([]byte("patched qux"))
+	patch.AddFile(qux)
+	subFs.Mount(patch)
+	if layer, _ := subFs.LayerOf("qux"); layer != 3 {
+		t.Errorf("LayerOf(qux): expected [3] got [%v]", layer)
+	}
+	if layer, _ := zfs.LayerOf("testdata/qux"); layer != 1 {
+		t.Errorf("LayerOf(testdata/qux): expected [1] got [%v]", layer)
+	}
+}
+
+func TestLayeredAssetManifest(t *testing.T) {
+	// Create base layer with the fingerprints.
+	zipper := NewZipper()
+	zipper.SetFingerprint("testdata/*", "testdata/dir/*")
+	zipper.Add("testdata/foo")
+	zipper.Add("testdata/dir")
+	zipper.Close()
+	zipBytes, _ := zipper.Bytes()
+	base, err := NewUnzipper(&zipBytes).Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	// Create theme layer with its own fingerprints.
+	dir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(fpath.Join(dir, "testdata", "dir"), 0755)
+	ioutil.WriteFile(fpath.Join(dir, "testdata", "foo"), []byte("new foo"), 0644)
+	ioutil.WriteFile(fpath.Join(dir, "testdata", "dir", ".wh.baz"), []byte{}, 0644)
+	ioutil.WriteFile(fpath.Join(dir, "testdata", "theme.css"), []byte("theme"), 0644)
+	zipper = NewZipper()
+	zipper.SetFingerprint("testdata/*.css")
+	zipper.AddAs(dir, ".")
+	zipper.Close()
+	zipBytes, _ = zipper.Bytes()
+	theme, err := NewUnzipper(&zipBytes).Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	// Stack layers.
+	zfs, err := NewLayeredFileSystem(base, theme)
+	if err != nil {
+		t.Fatalf("NewLayeredFileSystem():error=[%v]", err)
+	}
+	// Replaced and hidden files lose the fingerprints of the base.
+	expected := map[string]string{
+		"testdata/dir/bar":   fingerprintPath("testdata/dir/bar", contentHash([]byte("bar"))),
+		"testdata/theme.css": fingerprintPath("testdata/theme.css", contentHash([]byte("theme"))),
+	}
+	manifest, err := zfs.AssetManifest()
+	if err != nil {
+		t.Fatalf("AssetManifest():error=[%v]", err)
+	}
+	if len(manifest) != len(expected) {
+		t.Errorf("AssetManifest(): expected [%v] got [%v]", expected, manifest)
+	}
+	for logical, fingerprinted := range expected {
+		if manifest[logical] != fingerprinted {
+			t.Errorf("AssetManifest(): expected [%v] got [%v]", fingerprinted, manifest[logical])
+		}
+	}
+	// Sub file system mounts the layer under its root.
+	subFs, _ := base.SubFileSystem("testdata")
+	subFs.Mount(theme)
+	if manifest, _ = subFs.AssetManifest(); manifest["dir/bar"] == "" ||
+		manifest["testdata/theme.css"] != expected["testdata/theme.css"] {
+		t.Errorf("AssetManifest(): got [%v]", manifest)
+	}
+}
@@ -0,0 +1,228 @@
+package zgok
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event of the request served by the file server.
+type RequestEvent struct {
+	Path     string        // URL path of the request.
+	File     string        // Path of the file served under the root. (Empty if none)
+	Method   string        // Method of the request.
+	Status   int           // Status code of the response.
+	Bytes    int64         // Body bytes of the response.
+	Encoding string        // Content encoding served. (Empty for identity)
+	CacheHit bool          // Is the response 304 Not Modified?
+	Latency  time.Duration // Time to serve the request.
+}
+
+// Observer of the requests.
+type Observer interface {
+	Observe(event RequestEvent) // Called after the request is served.
+}
+
+// Observer function.
+type ObserverFunc func(event RequestEvent)
+
+// Implements [Observer.Observe]
+func (f ObserverFunc) Observe(event RequestEvent) {
+	f(event)
+}
+
+// Add the observer of the requests.
+// Observers are called synchronously after every request.
+// (ex. WithObserver(ObserverFunc(func(e RequestEvent) { log.Println(e.Path, e.Status) })))
+func WithObserver(observer Observer) ServerOption {
+	return func(s *fileServer) {
+		s.observers = append(s.observers, observer)
+	}
+}
+
+// Response writer recording the status, the bytes and the file served.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int    // Status code written.
+	bytes  int64  // Body bytes written.
+	file   string // Path of the file served.
+}
+
+// Implements [net/http.ResponseWriter.WriteHeader]
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+// Implements [net/http.ResponseWriter.Write]
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(p)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Report the served request to the observers.
+func (s *fileServer) observe(r *http.Request, recorder *responseRecorder, latency time.Duration) {
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	event := RequestEvent{
+		Path:     r.URL.Path,
+		File:     recorder.file,
+		Method:   r.Method,
+		Status:   status,
+		Bytes:    recorder.bytes,
+		Encoding: recorder.Header().Get("Content-Encoding"),
+		CacheHit: status == http.StatusNotModified,
+		Latency:  latency,
+	}
+	for _, observer := range s.observers {
+		observer.Observe(event)
+	}
+}
+
+// Request metrics by the files served.
+// Exposed in the Prometheus text format by ServeHTTP, and in JSON as an
+// expvar variable. (ex. expvar.Publish("zgok", metrics))
+// The responses other than the files (errors, redirects and listings) are
+// counted under the empty path not to grow the labels by the request URLs.
+type Metrics struct {
+	mutex sync.Mutex              // Mutex for the metrics.
+	paths map[string]*pathMetrics // Metrics by the file paths.
+}
+
+// Metrics of the path.
+type pathMetrics struct {
+	Requests       int64            `json:"requests"`       // Count of the requests.
+	Bytes          int64            `json:"bytes"`          // Total body bytes.
+	CacheHits      int64            `json:"cacheHits"`      // Count of 304 responses.
+	LatencySeconds float64          `json:"latencySeconds"` // Total latency in seconds.
+	Statuses       map[string]int64 `json:"statuses"`       // Counts by the status codes.
+	Encodings      map[string]int64 `json:"encodings"`      // Counts by the encodings.
+}
+
+// Create new metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{paths: make(map[string]*pathMetrics)}
+}
+
+// Count the request.
+// Implements [Observer.Observe]
+func (m *Metrics) Observe(event RequestEvent) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := event.File
+	if event.Status >= 400 {
+		key = ""
+	}
+	pm, exists := m.paths[key]
+	if !exists {
+		pm = &pathMetrics{
+			Statuses:  make(map[string]int64),
+			Encodings: make(map[string]int64),
+		}
+		m.paths[key] = pm
+	}
+	pm.Requests++
+	pm.Bytes += event.Bytes
+	if event.CacheHit {
+		pm.CacheHits++
+	}
+	pm.LatencySeconds += event.Latency.Seconds()
+	pm.Statuses[strconv.Itoa(event.Status)]++
+	encoding := event.Encoding
+	if encoding == "" {
+		encoding = "identity"
+	}
+	pm.Encodings[encoding]++
+}
+
+// Get the metrics in JSON.
+// Implements [expvar.Var.String]
+func (m *Metrics) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	content, err := json.Marshal(m.paths)
+	if err != nil {
+		return "{}"
+	}
+	return string(content)
+}
+
+// Serve the metrics in the Prometheus text format.
+// Implements [net/http.Handler.ServeHTTP]
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	paths := make([]string, 0, len(m.paths))
+	for path := range m.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	// Write the metric family with the samples of all the paths.
+	family := func(name, kind, help string, sample func(path string, pm *pathMetrics)) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, path := range paths {
+			sample(path, m.paths[path])
+		}
+	}
+	family("zgok_http_requests_total", "counter", "Count of the requests by the status codes.",
+		func(path string, pm *pathMetrics) {
+			for _, status := range sortedKeys(pm.Statuses) {
+				fmt.Fprintf(&b, "zgok_http_requests_total{path=\"%s\",status=\"%s\"} %d\n",
+					escapeLabel(path), status, pm.Statuses[status])
+			}
+		})
+	family("zgok_http_responses_by_encoding_total", "counter", "Count of the responses by the encodings.",
+		func(path string, pm *pathMetrics) {
+			for _, encoding := range sortedKeys(pm.Encodings) {
+				fmt.Fprintf(&b, "zgok_http_responses_by_encoding_total{path=\"%s\",encoding=\"%s\"} %d\n",
+					escapeLabel(path), escapeLabel(encoding), pm.Encodings[encoding])
+			}
+		})
+	family("zgok_http_response_bytes_total", "counter", "Total body bytes of the responses.",
+		func(path string, pm *pathMetrics) {
+			fmt.Fprintf(&b, "zgok_http_response_bytes_total{path=\"%s\"} %d\n", escapeLabel(path), pm.Bytes)
+		})
+	family("zgok_http_cache_hits_total", "counter", "Count of the 304 responses.",
+		func(path string, pm *pathMetrics) {
+			fmt.Fprintf(&b, "zgok_http_cache_hits_total{path=\"%s\"} %d\n", escapeLabel(path), pm.CacheHits)
+		})
+	family("zgok_http_request_duration_seconds", "summary", "Latency of the requests.",
+		func(path string, pm *pathMetrics) {
+			fmt.Fprintf(&b, "zgok_http_request_duration_seconds_sum{path=\"%s\"} %g\n", escapeLabel(path), pm.LatencySeconds)
+			fmt.Fprintf(&b, "zgok_http_request_duration_seconds_count{path=\"%s\"} %d\n", escapeLabel(path), pm.Requests)
+		})
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// Escaper of the label values in the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Escape the label value in the Prometheus text format.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// Get the sorted keys of the counts.
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
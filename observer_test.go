@@ -0,0 +1,105 @@
+package zgok
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFileServerObserver(t *testing.T) {
+	// Build zgok file.
+	outPath := "observer_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	var events []RequestEvent
+	metrics := NewMetrics()
+	ts := httptest.NewServer(zfs.FileServer("testdata",
+		WithSPAFallback("foo"),
+		WithObserver(ObserverFunc(func(event RequestEvent) {
+			events = append(events, event)
+		})),
+		WithObserver(metrics)))
+	defer ts.Close()
+	get := func(urlPath, etag string) *http.Response {
+		req, _ := http.NewRequest("GET", ts.URL+urlPath, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Get [%s] failed.", urlPath)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return res
+	}
+	res := get("/foo", "")
+	get("/foo", res.Header.Get("ETag"))
+	get("/missing.txt", "")
+	get("/app/random-1", "")
+	get("/app/random-2", "")
+	// Verify the events.
+	expected := []RequestEvent{
+		{Path: "/foo", File: "foo", Method: "GET", Status: http.StatusOK, Bytes: 3},
+		{Path: "/foo", File: "foo", Method: "GET", Status: http.StatusNotModified, CacheHit: true},
+		{Path: "/missing.txt", Method: "GET", Status: http.StatusNotFound, Bytes: 19},
+		{Path: "/app/random-1", File: "foo", Method: "GET", Status: http.StatusOK, Bytes: 3},
+		{Path: "/app/random-2", File: "foo", Method: "GET", Status: http.StatusOK, Bytes: 3},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected [%v] events got [%v]", len(expected), events)
+	}
+	for i, event := range events {
+		if event.Latency < 0 {
+			t.Errorf("[%v] expected non-negative latency got [%v]", i, event.Latency)
+		}
+		event.Latency = 0
+		if event != expected[i] {
+			t.Errorf("[%v] expected [%+v] got [%+v]", i, expected[i], event)
+		}
+	}
+	// Verify the Prometheus text format.
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	exposition := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE zgok_http_requests_total counter",
+		`zgok_http_requests_total{path="foo",status="200"} 3`,
+		`zgok_http_requests_total{path="foo",status="304"} 1`,
+		`zgok_http_requests_total{path="",status="404"} 1`,
+		`zgok_http_responses_by_encoding_total{path="foo",encoding="identity"} 4`,
+		`zgok_http_response_bytes_total{path="foo"} 9`,
+		`zgok_http_cache_hits_total{path="foo"} 1`,
+		`zgok_http_request_duration_seconds_count{path="foo"} 4`,
+	} {
+		if !strings.Contains(exposition, line+"\n") {
+			t.Errorf(`expected "%v" in "%v"`, line, exposition)
+		}
+	}
+	// Verify the expvar variable.
+	var paths map[string]pathMetrics
+	err = json.Unmarshal([]byte(metrics.String()), &paths)
+	if err != nil {
+		t.Fatalf("json.Unmarshal():error=[%v]", err)
+	}
+	// Only the files served are the keys, not the request URLs.
+	if len(paths) != 2 || paths["foo"].Requests != 4 || paths["foo"].CacheHits != 1 || paths[""].Requests != 1 {
+		t.Errorf("String(): got [%v]", metrics.String())
+	}
+	if escapeLabel("a\"b\\c\n") != `a\"b\\c\n` {
+		t.Errorf("escapeLabel(): got [%v]", escapeLabel("a\"b\\c\n"))
+	}
+}
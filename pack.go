@@ -0,0 +1,33 @@
+package zgok
+
+import (
+	"fmt"
+)
+
+const (
+	PACK_EXTENSION = ".zgok" // Extension of the asset pack file.
+)
+
+// Restore asset pack file. (zip and signature without exe)
+func RestorePack(path string, options ...RestoreOption) (FileSystem, error) {
+	pack, err := RestoreFileSystem(path, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !pack.Signature().IsPack() {
+		return nil, fmt.Errorf("not an asset pack [%s]", path)
+	}
+	return pack, nil
+}
+
+// Restore asset pack files and mount them on the file system in order.
+func MountPacks(zfs FileSystem, paths []string, options ...RestoreOption) error {
+	for _, path := range paths {
+		pack, err := RestorePack(path, options...)
+		if err != nil {
+			return err
+		}
+		zfs.Mount(pack)
+	}
+	return nil
+}
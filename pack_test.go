@@ -0,0 +1,71 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"testing"
+)
+
+func TestBuildMountPack(t *testing.T) {
+	// Build zgok file.
+	outPath := "pack_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	// Build asset pack overriding "testdata/foo".
+	dir, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(fpath.Join(dir, "foo"), []byte("new foo"), 0644)
+	ioutil.WriteFile(fpath.Join(dir, "qux"), []byte("qux"), 0644)
+	packPath := "pack_test.out" + PACK_EXTENSION
+	builder = NewZgokBuilder()
+	builder.AddZipPathAs(fpath.Join(dir, "foo"), "testdata/foo")
+	builder.AddZipPathAs(fpath.Join(dir, "qux"), "testdata/qux")
+	builder.SetOutPath(packPath)
+	err = builder.BuildPack()
+	if err != nil {
+		t.Fatalf("BuildPack():error=[%v]", err)
+	}
+	// Restore asset pack.
+	pack, err := RestorePack(packPath)
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	if !pack.Signature().IsPack() || pack.Signature().ExeSize() != 0 {
+		t.Errorf("Signature():unexpected [%v].", pack.Signature())
+	}
+	if _, err = RestorePack(outPath); err == nil {
+		t.Errorf("Expected error on restoring executable as asset pack.")
+	}
+	// Mount asset pack.
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	err = MountPacks(zfs, []string{packPath})
+	if err != nil {
+		t.Fatalf("MountPacks():error=[%v]", err)
+	}
+	fooStr, _ := zfs.ReadFileString("testdata/foo")
+	if fooStr != "new foo" {
+		t.Errorf(`expected "new foo" got "%v"`, fooStr)
+	}
+	quxStr, _ := zfs.ReadFileString("testdata/qux")
+	if quxStr != "qux" {
+		t.Errorf(`expected "qux" got "%v"`, quxStr)
+	}
+	barStr, _ := zfs.ReadFileString("testdata/dir/bar")
+	if barStr != "bar" {
+		t.Errorf(`expected "bar" got "%v"`, barStr)
+	}
+}
@@ -0,0 +1,169 @@
+package zgok
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Build report.
+type BuildReport struct {
+	ExeSize        int64        `json:"exeSize"`        // Executable byte size.
+	ZipSize        int64        `json:"zipSize"`        // Zip byte size.
+	SignatureSize  int64        `json:"signatureSize"`  // Signature byte size.
+	TotalSize      int64        `json:"totalSize"`      // Total byte size.
+	OriginalSize   int64        `json:"originalSize"`   // Total size of the files.
+	CompressedSize int64        `json:"compressedSize"` // Total compressed size of the files.
+	Ratio          float64      `json:"ratio"`          // Compression ratio.
+	Files          []FileReport `json:"files"`          // Reports of the files.
+	Dirs           []DirReport  `json:"dirs"`           // Reports of the directories.
+}
+
+// File report.
+type FileReport struct {
+	Path           string  `json:"path"`           // Path of the file.
+	Size           int64   `json:"size"`           // Original byte size.
+	CompressedSize int64   `json:"compressedSize"` // Compressed byte size.
+	Ratio          float64 `json:"ratio"`          // Compression ratio.
+	SHA256         string  `json:"sha256"`         // Hex SHA-256 of the content.
+	CRC32          uint32  `json:"crc32"`          // CRC-32 of the content.
+	ContentType    string  `json:"contentType"`    // Content type recorded in the payload.
+}
+
+// Directory report.
+type DirReport struct {
+	Path           string  `json:"path"`           // Path of the directory.
+	FileCount      int     `json:"fileCount"`      // Number of the files.
+	Size           int64   `json:"size"`           // Original byte size.
+	CompressedSize int64   `json:"compressedSize"` // Compressed byte size.
+	Ratio          float64 `json:"ratio"`          // Compression ratio.
+}
+
+// Create a build report from the exe size and the zip bytes.
+func NewBuildReport(exeSize int64, zipBytes []byte) (*BuildReport, error) {
+	zipSize := int64(len(zipBytes))
+	zipReader, err := zip.NewReader(bytes.NewReader(zipBytes), zipSize)
+	if err != nil {
+		return nil, err
+	}
+	r := &BuildReport{
+		ExeSize:       exeSize,
+		ZipSize:       zipSize,
+		SignatureSize: SIGNATURE_BYTE_SIZE,
+		TotalSize:     exeSize + zipSize + SIGNATURE_BYTE_SIZE,
+		Files:         []FileReport{},
+		Dirs:          []DirReport{},
+	}
+	dirMap := make(map[string]*DirReport)
+	prefix := APP + "/"
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() || !strings.HasPrefix(file.Name, prefix) {
+			continue
+		}
+		fr := FileReport{
+			Path:           strings.TrimPrefix(file.Name, prefix),
+			Size:           int64(file.UncompressedSize64),
+			CompressedSize: int64(file.CompressedSize64),
+			SHA256:         hex.EncodeToString(parseHashExtra(file.Extra)),
+			CRC32:          file.CRC32,
+			ContentType:    parseContentTypeExtra(file.Extra),
+		}
+		fr.Ratio = compressionRatio(fr.Size, fr.CompressedSize)
+		r.Files = append(r.Files, fr)
+		r.OriginalSize += fr.Size
+		r.CompressedSize += fr.CompressedSize
+		// Sum up to all the parent directories.
+		for dir := path.Dir(fr.Path); ; dir = path.Dir(dir) {
+			dr, exists := dirMap[dir]
+			if !exists {
+				dr = &DirReport{Path: dir}
+				dirMap[dir] = dr
+			}
+			dr.FileCount++
+			dr.Size += fr.Size
+			dr.CompressedSize += fr.CompressedSize
+			if dir == "." {
+				break
+			}
+		}
+	}
+	r.Ratio = compressionRatio(r.OriginalSize, r.CompressedSize)
+	sort.Slice(r.Files, func(i, j int) bool {
+		return r.Files[i].Path < r.Files[j].Path
+	})
+	for _, dr := range dirMap {
+		dr.Ratio = compressionRatio(dr.Size, dr.CompressedSize)
+		r.Dirs = append(r.Dirs, *dr)
+	}
+	sort.Slice(r.Dirs, func(i, j int) bool {
+		return r.Dirs[i].Path < r.Dirs[j].Path
+	})
+	return r, nil
+}
+
+// Get the n largest files by the compressed size.
+func (r *BuildReport) Largest(n int) []FileReport {
+	files := make([]FileReport, len(r.Files))
+	copy(files, r.Files)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].CompressedSize > files[j].CompressedSize
+	})
+	if 0 <= n && n < len(files) {
+		files = files[:n]
+	}
+	return files
+}
+
+// Write the report in text.
+func (r *BuildReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Sizes:")
+	fmt.Fprintf(tw, "  exe:\t%d\n", r.ExeSize)
+	fmt.Fprintf(tw, "  zip:\t%d\n", r.ZipSize)
+	fmt.Fprintf(tw, "  signature:\t%d\n", r.SignatureSize)
+	fmt.Fprintf(tw, "  total:\t%d\n", r.TotalSize)
+	fmt.Fprintf(tw, "  files:\t%d -> %d (%.1f%%)\n",
+		r.OriginalSize, r.CompressedSize, r.Ratio*100)
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Largest files:")
+	fmt.Fprintln(tw, "  SIZE\tCOMPRESSED\tRATIO\tPATH")
+	for _, fr := range r.Largest(10) {
+		fmt.Fprintf(tw, "  %d\t%d\t%.1f%%\t%s\n",
+			fr.Size, fr.CompressedSize, fr.Ratio*100, fr.Path)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Directories:")
+	fmt.Fprintln(tw, "  FILES\tSIZE\tCOMPRESSED\tRATIO\tPATH")
+	for _, dr := range r.Dirs {
+		fmt.Fprintf(tw, "  %d\t%d\t%d\t%.1f%%\t%s\n",
+			dr.FileCount, dr.Size, dr.CompressedSize, dr.Ratio*100, dr.Path)
+	}
+	return tw.Flush()
+}
+
+// Get the report in text.
+func (r *BuildReport) Text() string {
+	buf := new(bytes.Buffer)
+	r.WriteText(buf)
+	return buf.String()
+}
+
+// Get the report in JSON.
+func (r *BuildReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Calculate the compression ratio. (compressed / original)
+func compressionRatio(size, compressedSize int64) float64 {
+	if size == 0 {
+		return 1
+	}
+	return float64(compressedSize) / float64(size)
+}
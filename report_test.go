@@ -0,0 +1,86 @@
+package zgok
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuildReport(t *testing.T) {
+	// Build zgok file.
+	outPath := "report_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	report := builder.Report()
+	if report == nil {
+		t.Fatalf("Report():expected report got nil.")
+	}
+	// Compare sizes.
+	outStat, _ := os.Stat(outPath)
+	if report.TotalSize != outStat.Size() {
+		t.Errorf("TotalSize:expected [%v] got [%v].", outStat.Size(), report.TotalSize)
+	}
+	if report.OriginalSize != 9 {
+		t.Errorf("OriginalSize:expected [%v] got [%v].", 9, report.OriginalSize)
+	}
+	if len(report.Files) != 3 || report.Files[2].Path != "testdata/foo" {
+		t.Errorf("Files:unexpected [%v].", report.Files)
+	}
+	fooHash := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	if len(report.Files) == 3 && report.Files[2].SHA256 != fooHash {
+		t.Errorf("SHA256:expected [%v] got [%v].", fooHash, report.Files[2].SHA256)
+	}
+	fooType := "text/plain; charset=utf-8"
+	if len(report.Files) == 3 && report.Files[2].ContentType != fooType {
+		t.Errorf("ContentType:expected [%v] got [%v].", fooType, report.Files[2].ContentType)
+	}
+	// Check directory totals.
+	for _, dr := range report.Dirs {
+		if dr.Path == "testdata/dir" && (dr.FileCount != 2 || dr.Size != 6) {
+			t.Errorf("Dirs:unexpected [%v].", dr)
+		}
+	}
+	if len(report.Largest(1)) != 1 {
+		t.Errorf("Largest(1):unexpected [%v].", report.Largest(1))
+	}
+	// Check formats.
+	if !strings.Contains(report.Text(), "testdata/dir/bar") {
+		t.Errorf("Text():unexpected [%v].", report.Text())
+	}
+	jsonBytes, err := report.JSON()
+	if err != nil {
+		t.Errorf("JSON():error=[%v]", err)
+	}
+	decoded := &BuildReport{}
+	err = json.Unmarshal(jsonBytes, decoded)
+	if err != nil || decoded.ZipSize != report.ZipSize {
+		t.Errorf("JSON():unexpected [%s].", jsonBytes)
+	}
+}
+
+func TestBuilderMaxSize(t *testing.T) {
+	outPath := "report_max_size_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	builder.SetMaxSize(10)
+	err := builder.Build()
+	if err == nil {
+		t.Errorf("Expected error on exceeding max size.")
+	}
+	if builder.Report() == nil {
+		t.Errorf("Expected report on exceeding max size.")
+	}
+	if _, err := os.Stat(outPath); err == nil {
+		t.Errorf("Output file should not be created on exceeding max size.")
+	}
+}
@@ -0,0 +1,110 @@
+package zgok
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Mount of the subtree or the file on the URL prefix.
+type Mount struct {
+	Prefix  string         // URL prefix. (ex. "/assets" or "/favicon.ico")
+	Path    string         // Path of the subtree or the file in the file system.
+	Options []ServerOption // Options of the file server.
+}
+
+// Router serving the mounts by the longest matching prefixes.
+type router struct {
+	routes []route // Routes sorted by the prefix lengths.
+}
+
+// Route of the mount.
+type route struct {
+	prefix  string       // URL prefix without the trailing slash.
+	file    string       // Base name of the mounted file. (Empty for the subtree)
+	handler http.Handler // File server of the mount.
+}
+
+// Get the handler routing the requests to the file servers of the mounts.
+// Prefixes are stripped, and the requests of the subtree prefixes without
+// the trailing slashes are redirected. Mounted files are served only at
+// their prefixes.
+func (zfs *zgokFileSystem) Router(mounts ...Mount) (http.Handler, error) {
+	rt := &router{}
+	prefixes := make(map[string]bool)
+	for _, mount := range mounts {
+		if !strings.HasPrefix(mount.Prefix, "/") {
+			return nil, fmt.Errorf("prefix must start with slash [%s]", mount.Prefix)
+		}
+		prefix := strings.TrimSuffix(path.Clean(mount.Prefix), "/")
+		if prefixes[prefix] {
+			return nil, fmt.Errorf("duplicate prefix [%s]", mount.Prefix)
+		}
+		prefixes[prefix] = true
+		// Mount the subtree or the file.
+		basePath, file := path.Clean("/" + mount.Path)[1:], ""
+		if basePath != "" {
+			target, err := zfs.Open(basePath)
+			if err != nil {
+				return nil, fmt.Errorf("mount path not found [%s]", mount.Path)
+			}
+			target.Close()
+			if isRegular(target.(File)) {
+				basePath, file = path.Dir(basePath), path.Base(basePath)
+			}
+		}
+		handler := zfs.FileServer(basePath, mount.Options...)
+		if handler == nil {
+			return nil, fmt.Errorf("invalid mount path [%s]", mount.Path)
+		}
+		rt.routes = append(rt.routes, route{prefix: prefix, file: file, handler: handler})
+	}
+	sort.SliceStable(rt.routes, func(i, j int) bool {
+		return len(rt.routes[i].prefix) > len(rt.routes[j].prefix)
+	})
+	return rt, nil
+}
+
+// Serve the request by the route of the longest matching prefix.
+// Implements [net/http.Handler.ServeHTTP]
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.URL.Path
+	for _, rte := range rt.routes {
+		// Serve the mounted file.
+		if rte.file != "" {
+			if urlPath == rte.prefix {
+				rte.handler.ServeHTTP(w, stripPrefix(r, "/"+rte.file))
+				return
+			}
+			continue
+		}
+		// Redirect to the subtree with the trailing slash.
+		if urlPath == rte.prefix {
+			target := rte.prefix + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if strings.HasPrefix(urlPath, rte.prefix+"/") {
+			rte.handler.ServeHTTP(w, stripPrefix(r, urlPath[len(rte.prefix):]))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// Get the copy of the request with the URL path.
+func stripPrefix(r *http.Request, urlPath string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = urlPath
+	r2.URL.RawPath = ""
+	return r2
+}
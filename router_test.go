@@ -0,0 +1,87 @@
+package zgok
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	// Build zgok file.
+	outPath := "router_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	zfs, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	// Verify errors.
+	invalids := [][]Mount{
+		{{Prefix: "assets", Path: "testdata/dir"}},
+		{{Prefix: "/assets", Path: "testdata/dir"}, {Prefix: "/assets/", Path: "testdata"}},
+		{{Prefix: "/assets", Path: "testdata/missing"}},
+	}
+	for _, mounts := range invalids {
+		if _, err = zfs.Router(mounts...); err == nil {
+			t.Errorf("Expected error on mounts [%v].", mounts)
+		}
+	}
+	handler, err := zfs.Router(
+		Mount{Prefix: "/", Path: "testdata"},
+		Mount{Prefix: "/assets/", Path: "testdata/dir",
+			Options: []ServerOption{WithCacheControl("*", "no-cache")}},
+		Mount{Prefix: "/favicon.ico", Path: "testdata/foo"},
+	)
+	if err != nil {
+		t.Fatalf("Router():error=[%v]", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	// Do not follow the redirects.
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	requests := []struct {
+		urlPath      string
+		status       int
+		body         string
+		location     string
+		cacheControl string
+	}{
+		{"/assets/bar", http.StatusOK, "bar", "", "no-cache"},
+		{"/assets?q=1", http.StatusMovedPermanently, "", "/assets/?q=1", ""},
+		{"/assets/missing", http.StatusNotFound, "", "", ""},
+		{"/favicon.ico", http.StatusOK, "foo", "", ""},
+		{"/favicon.ico/", http.StatusNotFound, "", "", ""},
+		{"/foo", http.StatusOK, "foo", "", ""},
+		{"/dir/baz", http.StatusOK, "baz", "", ""},
+	}
+	for _, request := range requests {
+		res, err := client.Get(ts.URL + request.urlPath)
+		if err != nil {
+			t.Fatalf("Get [%v] failed.", request.urlPath)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != request.status {
+			t.Errorf("[%v] expected [%v] got [%v]", request.urlPath, request.status, res.StatusCode)
+		}
+		if request.body != "" && string(content) != request.body {
+			t.Errorf(`[%v] expected "%v" got "%v"`, request.urlPath, request.body, string(content))
+		}
+		if res.Header.Get("Location") != request.location {
+			t.Errorf(`[%v] expected "%v" got "%v"`, request.urlPath, request.location, res.Header.Get("Location"))
+		}
+		if res.Header.Get("Cache-Control") != request.cacheControl {
+			t.Errorf(`[%v] expected "%v" got "%v"`, request.urlPath, request.cacheControl, res.Header.Get("Cache-Control"))
+		}
+	}
+}
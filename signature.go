@@ -18,6 +18,7 @@ type Signature interface {
 	ZipSize() int64
 	SetZipSize(zipSize int64)
 	TotalSize() int64
+	IsPack() bool
 	String() string
 	Dump() ([]byte, error)
 }
@@ -97,7 +98,8 @@ func RestoreSignature(data []byte) (Signature, error) {
 	if err != nil {
 		return nil, err
 	}
-	if exeSize <= 0 {
+	// Exe size is 0 for asset packs.
+	if exeSize < 0 {
 		return nil, fmt.Errorf("invalid signature")
 	}
 	s.exeSize = exeSize
@@ -154,8 +156,17 @@ func (s *zgokSignature) TotalSize() int64 {
 	return s.exeSize + s.zipSize + SIGNATURE_BYTE_SIZE
 }
 
+// Check if it is the signature of an asset pack. (without exe)
+func (s *zgokSignature) IsPack() bool {
+	return s.exeSize == 0
+}
+
 // Convert to string.
 func (s *zgokSignature) String() string {
+	if s.IsPack() {
+		return fmt.Sprintf("%s(pack,zip:%d,total:%d)",
+			Version(), s.zipSize, s.TotalSize())
+	}
 	return fmt.Sprintf("%s(exe:%d,zip:%d,total:%d)",
 		Version(), s.exeSize, s.zipSize, s.TotalSize())
 }
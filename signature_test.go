@@ -33,5 +33,25 @@ func TestSignatureDumpRestore(t *testing.T) {
 		t.Errorf("Compare zip size: expected [%v] got [%v]",
 			orig.ZipSize(), copy.ZipSize())
 	}
+}
 
+func TestSignaturePack(t *testing.T) {
+	// Asset pack has no exe.
+	orig := NewSignature()
+	orig.SetExeSize(0)
+	orig.SetZipSize(100)
+	bytes, _ := orig.Dump()
+	copy, err := RestoreSignature(bytes)
+	if err != nil {
+		t.Fatalf("RestoreSignature() failed: %v", err)
+	}
+	if !copy.IsPack() {
+		t.Errorf("IsPack(): expected [true] got [false]")
+	}
+	// Negative exe size is invalid.
+	orig.SetExeSize(-1)
+	bytes, _ = orig.Dump()
+	if _, err = RestoreSignature(bytes); err == nil {
+		t.Errorf("Expected error on negative exe size.")
+	}
 }
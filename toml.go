@@ -0,0 +1,245 @@
+package zgok
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse a subset of TOML used by the build configuration.
+// Supported: comments, [table], [[array of tables]], bare and quoted keys,
+// basic and literal strings, integers, floats, booleans and arrays.
+// Unsupported: dotted keys, inline tables, multi-line strings and dates.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	current := root
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		// Array of tables.
+		if strings.HasPrefix(line, "[[") {
+			if !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("line %d: invalid table header", lineNo)
+			}
+			key, err := parseTOMLKey(line[2 : len(line)-2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			tables, _ := root[key].([]interface{})
+			if _, exists := root[key]; exists && tables == nil {
+				return nil, fmt.Errorf("line %d: duplicate key [%s]", lineNo, key)
+			}
+			current = make(map[string]interface{})
+			root[key] = append(tables, current)
+			continue
+		}
+		// Table.
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table header", lineNo)
+			}
+			key, err := parseTOMLKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			if _, exists := root[key]; exists {
+				return nil, fmt.Errorf("line %d: duplicate key [%s]", lineNo, key)
+			}
+			current = make(map[string]interface{})
+			root[key] = current
+			continue
+		}
+		// Key value pair.
+		pos := strings.Index(line, "=")
+		if pos < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key, err := parseTOMLKey(line[:pos])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if _, exists := current[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key [%s]", lineNo, key)
+		}
+		// Join the following lines of the multi-line array.
+		valueNo := lineNo
+		valueStr := strings.TrimSpace(line[pos+1:])
+		for tomlArrayDepth(valueStr) > 0 {
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("line %d: %s: unterminated array", valueNo, key)
+			}
+			lineNo++
+			valueStr += " " + strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		}
+		value, err := parseTOMLValue(valueStr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", valueNo, key, err)
+		}
+		current[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Strip the comment outside of strings.
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote != 0 && c == quote && (quote == '\'' || i == 0 || line[i-1] != '\\'):
+			quote = 0
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Get the depth of the unclosed arrays outside of strings.
+func tomlArrayDepth(s string) int {
+	depth := 0
+	var quote rune
+	for i, c := range s {
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote != 0 && c == quote && (quote == '\'' || i == 0 || s[i-1] != '\\'):
+			quote = 0
+		case quote == 0 && c == '[':
+			depth++
+		case quote == 0 && c == ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// Parse a key.
+func parseTOMLKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		value, rest, err := parseTOMLString(s)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return "", fmt.Errorf("invalid key [%s]", s)
+		}
+		return value, nil
+	}
+	for _, c := range s {
+		if !(c == '_' || c == '-' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return "", fmt.Errorf("invalid key [%s]", s)
+		}
+	}
+	return s, nil
+}
+
+// Parse a value.
+func parseTOMLValue(s string) (interface{}, error) {
+	value, rest, err := parseTOMLValuePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("unexpected [%s]", rest)
+	}
+	return value, nil
+}
+
+// Parse a value at the beginning of the string and return the rest.
+func parseTOMLValuePrefix(s string) (interface{}, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"', '\'':
+		return parseTOMLString(s)
+	case '[':
+		return parseTOMLArray(s)
+	case '{':
+		return nil, "", fmt.Errorf("inline tables are unsupported")
+	}
+	// Scalar values end at a comma or a closing bracket.
+	end := strings.IndexAny(s, ",]")
+	if end < 0 {
+		end = len(s)
+	}
+	token := strings.TrimSpace(s[:end])
+	rest := s[end:]
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	numStr := strings.Replace(token, "_", "", -1)
+	if i, err := strconv.ParseInt(numStr, 0, 64); err == nil {
+		return i, rest, nil
+	}
+	if f, err := strconv.ParseFloat(numStr, 64); err == nil {
+		return f, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value [%s]", token)
+}
+
+// Parse a basic or literal string.
+func parseTOMLString(s string) (string, string, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] != quote {
+			continue
+		}
+		if quote == '\'' {
+			return s[1:i], s[i+1:], nil
+		}
+		value, err := strconv.Unquote(s[:i+1])
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+		}
+		return value, s[i+1:], nil
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// Parse an array.
+func parseTOMLArray(s string) ([]interface{}, string, error) {
+	values := []interface{}{}
+	rest := strings.TrimSpace(s[1:])
+	for {
+		if strings.HasPrefix(rest, "]") {
+			return values, rest[1:], nil
+		}
+		value, next, err := parseTOMLValuePrefix(rest)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+		rest = strings.TrimSpace(next)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+	}
+}
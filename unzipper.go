@@ -3,27 +3,138 @@ package zgok
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
 )
 
 // Unzipper.
 type Unzipper struct {
-	isUnzipped bool          // Is the file already unzipped?
-	reader     *bytes.Reader // Byte reader.
-	size       int64         // Size of the zipped file.
+	isUnzipped   bool             // Is the file already unzipped?
+	reader       io.ReaderAt      // Zip reader.
+	size         int64            // Size of the zipped file.
+	unsafePath   UnsafePathPolicy // Policy for the unsafe entry names.
+	quarantined  []error          // Errors of the quarantined entries.
+	onQuarantine func(err error)  // Handler of the quarantined entries.
+	limits       Limits           // Resource limits.
+	keepDeflate  bool             // Keep the raw deflate streams?
+}
+
+// Resource limits on unzip. Zero values mean unlimited.
+type Limits struct {
+	MaxTotalSize int64   // Max total uncompressed byte size.
+	MaxFileSize  int64   // Max uncompressed byte size of a file.
+	MaxEntries   int     // Max number of entries.
+	MaxRatio     float64 // Max compression ratio. (uncompressed / compressed)
+}
+
+// Kind of the resource limit.
+type LimitKind int
+
+const (
+	LIMIT_TOTAL_SIZE LimitKind = iota // Max total uncompressed byte size.
+	LIMIT_FILE_SIZE                   // Max uncompressed byte size of a file.
+	LIMIT_ENTRIES                     // Max number of entries.
+	LIMIT_RATIO                       // Max compression ratio.
+)
+
+// Get name of the limit kind.
+func (k LimitKind) String() string {
+	switch k {
+	case LIMIT_TOTAL_SIZE:
+		return "max total size"
+	case LIMIT_FILE_SIZE:
+		return "max file size"
+	case LIMIT_ENTRIES:
+		return "max entries"
+	case LIMIT_RATIO:
+		return "max compression ratio"
+	}
+	return "unknown limit"
+}
+
+// Error on exceeding the resource limit.
+type LimitError struct {
+	Kind  LimitKind // Kind of the exceeded limit.
+	Name  string    // Entry name in zip. (Empty for the archive)
+	Value float64   // Declared or actual value.
+	Max   float64   // Limit value.
+}
+
+// Get error message.
+func (e *LimitError) Error() string {
+	value := strconv.FormatFloat(e.Value, 'f', -1, 64)
+	max := strconv.FormatFloat(e.Max, 'f', -1, 64)
+	if e.Name == "" {
+		return fmt.Sprintf("%v exceeded: %s > %s", e.Kind, value, max)
+	}
+	return fmt.Sprintf("%v exceeded by %q: %s > %s", e.Kind, e.Name, value, max)
+}
+
+// Policy for the unsafe entry names.
+type UnsafePathPolicy int
+
+const (
+	UNSAFE_PATH_REJECT     UnsafePathPolicy = iota // Fail to unzip.
+	UNSAFE_PATH_QUARANTINE                         // Skip the entry and record the error.
+)
+
+// Error on the unsafe entry name.
+type UnsafePathError struct {
+	Name   string // Entry name in zip.
+	Reason string // Reason why the name is unsafe.
+}
+
+// Get error message.
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe path %q in zip: %s", e.Name, e.Reason)
 }
 
 // Create new unzipper.
 func NewUnzipper(zipBytes *[]byte) *Unzipper {
+	return NewUnzipperReaderAt(bytes.NewReader(*zipBytes), int64(len(*zipBytes)))
+}
+
+// Create new unzipper from the reader of the given size.
+func NewUnzipperReaderAt(r io.ReaderAt, size int64) *Unzipper {
 	u := &Unzipper{
 		isUnzipped: false,
-		reader:     bytes.NewReader(*zipBytes),
-		size:       int64(len(*zipBytes)),
+		reader:     r,
+		size:       size,
 	}
 	return u
 }
 
+// Set policy for the unsafe entry names.
+func (u *Unzipper) SetUnsafePathPolicy(policy UnsafePathPolicy) {
+	u.unsafePath = policy
+}
+
+// Set resource limits.
+func (u *Unzipper) SetLimits(limits Limits) {
+	u.limits = limits
+}
+
+// Set whether to keep the raw deflate streams of the compressed files.
+// Streams are served as gzip without recompressing, at the cost of memory.
+func (u *Unzipper) SetKeepDeflate(keep bool) {
+	u.keepDeflate = keep
+}
+
+// Set the handler called with the error of every quarantined entry.
+func (u *Unzipper) SetQuarantineHandler(handler func(err error)) {
+	u.onQuarantine = handler
+}
+
+// Get errors of the entries skipped by the quarantine policy.
+func (u *Unzipper) Quarantined() []error {
+	return u.quarantined
+}
+
 // Unzip all the files in zip.
 func (u *Unzipper) Unzip() (FileSystem, error) {
 	var err error
@@ -36,39 +147,223 @@ func (u *Unzipper) Unzip() (FileSystem, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Check declared sizes.
+	err = u.checkDeclaredSizes(zipReader.File)
+	if err != nil {
+		return nil, err
+	}
 	// Prepare file system.
 	zfs := NewFileSystem()
 	// Get all files.
 	var readCloser io.ReadCloser
+	var totalSize int64
+	names := make(map[string]bool)
 	for _, file := range zipReader.File {
+		// Validate entry name.
+		path, err := cleanEntryName(file.FileHeader.Name)
+		if err == nil && names[path] {
+			err = &UnsafePathError{Name: file.FileHeader.Name, Reason: "duplicate entry"}
+		}
+		if err != nil {
+			if u.unsafePath == UNSAFE_PATH_QUARANTINE {
+				u.quarantined = append(u.quarantined, err)
+				if u.onQuarantine != nil {
+					u.onQuarantine(err)
+				}
+				continue
+			}
+			return nil, err
+		}
+		names[path] = true
 		// Initialize zgok file.
 		zgokFile := NewZgokFile()
 		// Set file path.
-		path := file.FileHeader.Name
 		zgokFile.SetPath(path)
 		// Set file info.
 		fileInfo := file.FileHeader.FileInfo()
 		zgokFile.SetFileInfo(fileInfo)
+		// Add directory without content.
+		if fileInfo.IsDir() {
+			zfs.AddFile(zgokFile)
+			continue
+		}
 		// Open file.
 		readCloser, err = file.Open()
 		if err != nil {
-			break
+			return nil, err
 		}
-		// Copy bytes.
+		// Copy bytes within the limits.
 		buf := new(bytes.Buffer)
-		_, err = io.Copy(buf, readCloser)
+		limit, kind := u.readLimit(file, totalSize)
+		var n int64
+		if limit < 0 {
+			n, err = io.Copy(buf, readCloser)
+		} else {
+			n, err = io.Copy(buf, io.LimitReader(readCloser, limit+1))
+		}
+		readCloser.Close()
 		if err != nil {
-			break
+			return nil, err
 		}
+		if 0 <= limit && limit < n {
+			return nil, u.limitError(kind, file, totalSize+n)
+		}
+		totalSize += n
 		zgokFile.SetBytes(buf.Bytes())
-		// Close file.
-		readCloser.Close()
+		// Set the recorded digests.
+		zgokFile.SetCRC32(file.CRC32)
+		hash := parseHashExtra(file.Extra)
+		if hash == nil {
+			hash = zgokFile.Hash()
+		}
+		zgokFile.SetHash(hash)
+		sha384, sha512 := parseIntegrityExtra(file.Extra)
+		zgokFile.SetDigest(SRI_SHA384, sha384)
+		zgokFile.SetDigest(SRI_SHA512, sha512)
+		zgokFile.SetContentType(parseContentTypeExtra(file.Extra))
+		// Keep the raw deflate stream of the compressed file.
+		if u.keepDeflate && file.Method == zip.Deflate &&
+			file.CompressedSize64 < file.UncompressedSize64 {
+			raw, err := u.readRaw(file)
+			if err != nil {
+				return nil, err
+			}
+			zgokFile.SetDeflate(raw)
+		}
 		// Add file to file system.
 		zfs.AddFile(zgokFile)
 	}
-	if err != nil {
-		return nil, err
+	// Set metadata stored in the zip comment.
+	if zipReader.Comment != "" {
+		metadata := make(map[string]string)
+		err = json.Unmarshal([]byte(zipReader.Comment), &metadata)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %v", err)
+		}
+		zfs.SetMetadata(metadata)
 	}
 	u.isUnzipped = true
 	return zfs, nil
 }
+
+// Read the raw compressed data of the file.
+func (u *Unzipper) readRaw(file *zip.File) ([]byte, error) {
+	offset, err := file.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, file.CompressedSize64)
+	_, err = io.ReadFull(io.NewSectionReader(u.reader, offset, int64(len(raw))), raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Check the number of the entries and the declared sizes.
+func (u *Unzipper) checkDeclaredSizes(files []*zip.File) error {
+	l := u.limits
+	if 0 < l.MaxEntries && l.MaxEntries < len(files) {
+		return &LimitError{Kind: LIMIT_ENTRIES,
+			Value: float64(len(files)), Max: float64(l.MaxEntries)}
+	}
+	var totalSize uint64
+	for _, file := range files {
+		size := file.UncompressedSize64
+		if 0 < l.MaxFileSize && uint64(l.MaxFileSize) < size {
+			return u.limitError(LIMIT_FILE_SIZE, file, int64(size))
+		}
+		totalSize += size
+		if 0 < l.MaxTotalSize && uint64(l.MaxTotalSize) < totalSize {
+			return u.limitError(LIMIT_TOTAL_SIZE, file, int64(totalSize))
+		}
+		if 0 < l.MaxRatio && l.MaxRatio < declaredRatio(file) {
+			return u.limitError(LIMIT_RATIO, file, int64(size))
+		}
+	}
+	return nil
+}
+
+// Get the max byte size to read from the file and the kind of the limit.
+// Returns -1 if there is no limit.
+func (u *Unzipper) readLimit(file *zip.File, totalSize int64) (int64, LimitKind) {
+	l := u.limits
+	limit, kind := int64(-1), LIMIT_FILE_SIZE
+	update := func(value int64, k LimitKind) {
+		if limit < 0 || value < limit {
+			limit, kind = value, k
+		}
+	}
+	if 0 < l.MaxFileSize {
+		update(l.MaxFileSize, LIMIT_FILE_SIZE)
+	}
+	if 0 < l.MaxTotalSize {
+		remaining := l.MaxTotalSize - totalSize
+		if remaining < 0 {
+			remaining = 0
+		}
+		update(remaining, LIMIT_TOTAL_SIZE)
+	}
+	if 0 < l.MaxRatio {
+		update(int64(l.MaxRatio*float64(file.CompressedSize64)), LIMIT_RATIO)
+	}
+	return limit, kind
+}
+
+// Create an error on exceeding the limit.
+func (u *Unzipper) limitError(kind LimitKind, file *zip.File, size int64) error {
+	e := &LimitError{Kind: kind, Name: file.Name, Value: float64(size)}
+	switch kind {
+	case LIMIT_TOTAL_SIZE:
+		e.Max = float64(u.limits.MaxTotalSize)
+	case LIMIT_FILE_SIZE:
+		e.Max = float64(u.limits.MaxFileSize)
+	case LIMIT_RATIO:
+		e.Value = float64(size) / math.Max(float64(file.CompressedSize64), 1)
+		e.Max = u.limits.MaxRatio
+	}
+	return e
+}
+
+// Get the declared compression ratio of the file.
+func declaredRatio(file *zip.File) float64 {
+	if file.UncompressedSize64 == 0 {
+		return 0
+	}
+	if file.CompressedSize64 == 0 {
+		return math.Inf(1)
+	}
+	return float64(file.UncompressedSize64) / float64(file.CompressedSize64)
+}
+
+// Normalize and validate the entry name in zip.
+// Names with NUL, backslashes, absolute paths and parent references are
+// rejected. Trailing slashes, "." and empty elements are removed.
+func cleanEntryName(name string) (string, error) {
+	unsafe := func(reason string) (string, error) {
+		return "", &UnsafePathError{Name: name, Reason: reason}
+	}
+	trimmed := strings.TrimSuffix(name, "/")
+	switch {
+	case trimmed == "":
+		return unsafe("empty name")
+	case strings.ContainsRune(name, 0):
+		return unsafe("NUL character")
+	case strings.Contains(name, `\`):
+		return unsafe("backslash")
+	case strings.HasPrefix(name, "/"):
+		return unsafe("absolute path")
+	case len(name) >= 2 && name[1] == ':':
+		return unsafe("drive letter")
+	}
+	for _, elem := range strings.Split(trimmed, "/") {
+		if elem == ".." {
+			return unsafe("parent directory reference")
+		}
+	}
+	cleaned := path.Clean(trimmed)
+	if cleaned == "." {
+		return unsafe("empty name")
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,258 @@
+package zgok
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Writable file system interface.
+type WritableFileSystem interface {
+	FileSystem
+	Create(path string) (io.WriteCloser, error)
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	Remove(path string) error
+	Rename(oldPath, newPath string) error
+	MkdirAll(path string, perm os.FileMode) error
+	SaveExecutable(exePath, outPath string) error
+	SavePack(outPath string) error
+}
+
+// Writable zgok file system.
+// Changes are kept in memory and never modify the files of the base.
+// Not safe for concurrent use.
+type writableFileSystem struct {
+	*zgokFileSystem
+}
+
+// Create a writable file system on the copy of the base file system.
+func NewWritableFileSystem(base FileSystem) (WritableFileSystem, error) {
+	zfs, err := NewLayeredFileSystem(base)
+	if err != nil {
+		return nil, err
+	}
+	wfs := &writableFileSystem{zfs.(*zgokFileSystem)}
+	// Changes are made on the new top layer.
+	wfs.topLayer++
+	// Copy metadata.
+	metadata := make(map[string]string)
+	for key, value := range base.Metadata() {
+		metadata[key] = value
+	}
+	wfs.SetMetadata(metadata)
+	return wfs, nil
+}
+
+// Create or truncate the file.
+// The content is written on closing the writer.
+func (wfs *writableFileSystem) Create(name string) (io.WriteCloser, error) {
+	perm := os.FileMode(0644)
+	if file, err := wfs.GetFile(name); err == nil && file.FileInfo() != nil {
+		perm = file.FileInfo().Mode().Perm()
+	}
+	// Check if the file can be written.
+	err := wfs.WriteFile(name, []byte{}, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{wfs: wfs, name: name, perm: perm}, nil
+}
+
+// Write data to the file.
+func (wfs *writableFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	key, err := wfs.writableKey(name)
+	if err != nil {
+		return err
+	}
+	if file, exists := wfs.fileMap[key]; exists && file.FileInfo().IsDir() {
+		return &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	// Create a new file not to modify the file of the base.
+	content := make([]byte, len(data))
+	copy(content, data)
+	wfs.putFile(key, zgokFileInfo{
+		name:    path.Base(key),
+		size:    int64(len(content)),
+		mode:    perm.Perm(),
+		modTime: time.Now(),
+	}, content)
+	return nil
+}
+
+// Remove the file or the empty directory.
+func (wfs *writableFileSystem) Remove(name string) error {
+	key := wfs.key(name)
+	if key == wfs.rootPath {
+		return &os.PathError{Op: "remove", Path: name, Err: fmt.Errorf("root directory")}
+	}
+	if wfs.hasChildren(key) {
+		return &os.PathError{Op: "remove", Path: name, Err: fmt.Errorf("directory not empty")}
+	}
+	if _, exists := wfs.fileMap[key]; !exists {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(wfs.fileMap, key)
+	delete(wfs.origins, key)
+	return nil
+}
+
+// Rename the file or the directory.
+func (wfs *writableFileSystem) Rename(oldPath, newPath string) error {
+	oldKey := wfs.key(oldPath)
+	newKey, err := wfs.writableKey(newPath)
+	if err != nil {
+		return err
+	}
+	_, exists := wfs.fileMap[oldKey]
+	if !exists && !wfs.hasChildren(oldKey) {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrNotExist}
+	}
+	if oldKey == wfs.rootPath || strings.HasPrefix(newKey+"/", oldKey+"/") {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath,
+			Err: fmt.Errorf("invalid argument")}
+	}
+	if file, exists := wfs.fileMap[newKey]; (exists && file.FileInfo().IsDir()) ||
+		wfs.hasChildren(newKey) {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath,
+			Err: fmt.Errorf("file exists")}
+	}
+	// Move the entry and its descendants.
+	prefix := oldKey + "/"
+	for key, file := range wfs.fileMap {
+		if key != oldKey && !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		movedKey := newKey + strings.TrimPrefix(key, oldKey)
+		delete(wfs.fileMap, key)
+		delete(wfs.origins, key)
+		fileInfo := file.FileInfo()
+		wfs.putFile(movedKey, zgokFileInfo{
+			name:    path.Base(movedKey),
+			size:    fileInfo.Size(),
+			mode:    fileInfo.Mode(),
+			modTime: fileInfo.ModTime(),
+		}, file.Bytes())
+	}
+	return nil
+}
+
+// Create the directory and its parents.
+func (wfs *writableFileSystem) MkdirAll(name string, perm os.FileMode) error {
+	if strings.Contains(filepath.ToSlash(name), "..") {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fmt.Errorf("invalid path")}
+	}
+	key := wfs.key(name)
+	relPath := strings.TrimPrefix(strings.TrimPrefix(key, wfs.rootPath), "/")
+	if relPath == "" {
+		return nil
+	}
+	dirKey := wfs.rootPath
+	for _, name := range strings.Split(relPath, "/") {
+		dirKey = dirKey + "/" + name
+		file, exists := wfs.fileMap[dirKey]
+		if exists && file.FileInfo().IsDir() {
+			continue
+		}
+		if exists {
+			return &os.PathError{Op: "mkdir", Path: name, Err: fmt.Errorf("not a directory")}
+		}
+		wfs.putFile(dirKey, zgokFileInfo{
+			name:    name,
+			mode:    os.ModeDir | perm.Perm(),
+			modTime: time.Now(),
+		}, nil)
+	}
+	return nil
+}
+
+// Save the file system with the exe as a new executable file.
+func (wfs *writableFileSystem) SaveExecutable(exePath, outPath string) error {
+	builder := wfs.newBuilder(outPath)
+	err := builder.SetExePath(exePath)
+	if err != nil {
+		return err
+	}
+	return builder.Build()
+}
+
+// Save the file system as a new asset pack file.
+func (wfs *writableFileSystem) SavePack(outPath string) error {
+	return wfs.newBuilder(outPath).BuildPack()
+}
+
+// Create a builder with the content of the file system.
+func (wfs *writableFileSystem) newBuilder(outPath string) Builder {
+	builder := NewZgokBuilder()
+	builder.AddFileSystem(wfs)
+	for key, value := range wfs.Metadata() {
+		builder.SetMetadata(key, value)
+	}
+	builder.SetOutPath(outPath)
+	return builder
+}
+
+// Get the key of the path.
+func (wfs *writableFileSystem) key(name string) string {
+	return filepath.ToSlash(filepath.Join(wfs.rootPath, name))
+}
+
+// Get the key of the path to write.
+// The parent directory must exist.
+func (wfs *writableFileSystem) writableKey(name string) (string, error) {
+	if strings.Contains(filepath.ToSlash(name), "..") {
+		return "", &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("invalid path")}
+	}
+	key := wfs.key(name)
+	if key == wfs.rootPath {
+		return "", &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("root directory")}
+	}
+	parentKey := path.Dir(key)
+	if parentKey == wfs.rootPath || wfs.hasChildren(parentKey) {
+		return key, nil
+	}
+	if parent, exists := wfs.fileMap[parentKey]; exists && parent.FileInfo().IsDir() {
+		return key, nil
+	}
+	return "", &os.PathError{Op: "write", Path: name, Err: os.ErrNotExist}
+}
+
+// Put a new file at the key.
+func (wfs *writableFileSystem) putFile(key string, fileInfo os.FileInfo, content []byte) {
+	file := NewZgokFile()
+	file.SetPath(key)
+	file.SetFileInfo(fileInfo)
+	file.SetBytes(content)
+	wfs.fileMap[key] = file
+	wfs.origins[key] = wfs.topLayer
+}
+
+// Writer of the file in the writable file system.
+type fileWriter struct {
+	wfs    *writableFileSystem // File system to write.
+	name   string              // Path of the file.
+	perm   os.FileMode         // Permission of the file.
+	buffer bytes.Buffer        // Written content.
+	closed bool                // Is the writer closed?
+}
+
+// Write content.
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	return w.buffer.Write(p)
+}
+
+// Write the content to the file system.
+func (w *fileWriter) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+	return w.wfs.WriteFile(w.name, w.buffer.Bytes(), w.perm)
+}
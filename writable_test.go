@@ -0,0 +1,158 @@
+package zgok
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWritableFileSystem(t *testing.T) {
+	// Build zgok file.
+	outPath := "writable_test.out"
+	builder := NewZgokBuilder()
+	builder.SetExePath("testdata/executable")
+	builder.AddZipPath("testdata/foo")
+	builder.AddZipPath("testdata/dir")
+	builder.SetMetadata("version", "1")
+	builder.SetOutPath(outPath)
+	err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build():error=[%v]", err)
+	}
+	base, err := RestoreFileSystem(outPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	wfs, err := NewWritableFileSystem(base)
+	if err != nil {
+		t.Fatalf("NewWritableFileSystem():error=[%v]", err)
+	}
+	// Modify files.
+	err = wfs.WriteFile("testdata/foo", []byte("new foo"), 0600)
+	if err != nil {
+		t.Errorf("WriteFile():error=[%v]", err)
+	}
+	err = wfs.MkdirAll("testdata/new/sub", 0755)
+	if err != nil {
+		t.Errorf("MkdirAll():error=[%v]", err)
+	}
+	w, err := wfs.Create("testdata/new/sub/qux")
+	if err != nil {
+		t.Fatalf("Create():error=[%v]", err)
+	}
+	w.Write([]byte("qux"))
+	w.Close()
+	err = wfs.Rename("testdata/dir", "testdata/moved")
+	if err != nil {
+		t.Errorf("Rename():error=[%v]", err)
+	}
+	err = wfs.Remove("testdata/moved/baz")
+	if err != nil {
+		t.Errorf("Remove():error=[%v]", err)
+	}
+	// Verify errors.
+	if err = wfs.Remove("testdata/new"); err == nil {
+		t.Errorf("Expected error on removing non-empty directory.")
+	}
+	if err = wfs.WriteFile("missing/file", []byte{}, 0644); !os.IsNotExist(err) {
+		t.Errorf("Expected not exist error got [%v].", err)
+	}
+	if err = wfs.Rename("testdata/foo", "testdata/new"); err == nil {
+		t.Errorf("Expected error on renaming to directory.")
+	}
+	if err = wfs.MkdirAll("a/../../../etc", 0755); err == nil {
+		t.Errorf("Expected error on making directory outside the root.")
+	}
+	// Verify changes.
+	expected := "testdata/foo,testdata/moved/bar,testdata/new/sub/qux"
+	if strings.Join(wfs.Paths(), ",") != expected {
+		t.Errorf("Paths(): expected [%v] got [%v]", expected, wfs.Paths())
+	}
+	if layer, _ := wfs.LayerOf("testdata/foo"); layer != 1 {
+		t.Errorf("LayerOf(): expected [1] got [%v]", layer)
+	}
+	// Base is not modified.
+	fooStr, _ := base.ReadFileString("testdata/foo")
+	if fooStr != "foo" {
+		t.Errorf(`expected "foo" got "%v"`, fooStr)
+	}
+	// Save as a new executable file from the built executable file.
+	savedPath := "writable_saved_test.out"
+	err = wfs.SaveExecutable(outPath, savedPath)
+	if err != nil {
+		t.Fatalf("SaveExecutable():error=[%v]", err)
+	}
+	saved, err := RestoreFileSystem(savedPath)
+	if err != nil {
+		t.Fatalf("RestoreFileSystem():error=[%v]", err)
+	}
+	if saved.Signature().ExeSize() != base.Signature().ExeSize() {
+		t.Errorf("ExeSize(): expected [%v] got [%v]",
+			base.Signature().ExeSize(), saved.Signature().ExeSize())
+	}
+	if strings.Join(saved.Paths(), ",") != expected {
+		t.Errorf("Paths(): expected [%v] got [%v]", expected, saved.Paths())
+	}
+	fooStr, _ = saved.ReadFileString("testdata/foo")
+	if fooStr != "new foo" {
+		t.Errorf(`expected "new foo" got "%v"`, fooStr)
+	}
+	if saved.Metadata()["version"] != "1" {
+		t.Errorf("Metadata(): unexpected [%v]", saved.Metadata())
+	}
+	// Save as a new asset pack file.
+	packPath := "writable_test.out" + PACK_EXTENSION
+	err = wfs.SavePack(packPath)
+	if err != nil {
+		t.Fatalf("SavePack():error=[%v]", err)
+	}
+	pack, err := RestorePack(packPath)
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	quxStr, _ := pack.ReadFileString("testdata/new/sub/qux")
+	if quxStr != "qux" {
+		t.Errorf(`expected "qux" got "%v"`, quxStr)
+	}
+	// Directories and symlinks are kept on saving.
+	err = wfs.MkdirAll("testdata/empty", 0750)
+	if err != nil {
+		t.Errorf("MkdirAll():error=[%v]", err)
+	}
+	link := NewZgokFile()
+	link.SetPath("zgok/testdata/link")
+	link.SetFileInfo(zgokFileInfo{name: "link", mode: os.ModeSymlink | 0777})
+	link.SetBytes([]byte("foo"))
+	wfs.AddFile(link)
+	err = wfs.SavePack(packPath)
+	if err != nil {
+		t.Fatalf("SavePack():error=[%v]", err)
+	}
+	pack, err = RestorePack(packPath)
+	if err != nil {
+		t.Fatalf("RestorePack():error=[%v]", err)
+	}
+	fileMap := pack.(*zgokFileSystem).fileMap
+	expectedModes := map[string]os.FileMode{
+		"zgok/testdata/empty": os.ModeDir | 0750,
+		"zgok/testdata/new":   os.ModeDir | 0755,
+		"zgok/testdata/link":  os.ModeSymlink | 0777,
+	}
+	for key, mode := range expectedModes {
+		file, exists := fileMap[key]
+		if !exists {
+			t.Errorf("[%v] expected entry", key)
+			continue
+		}
+		if file.FileInfo().Mode() != mode {
+			t.Errorf("[%v] expected mode [%v] got [%v]", key, mode, file.FileInfo().Mode())
+		}
+	}
+	if target := string(fileMap["zgok/testdata/link"].Bytes()); target != "foo" {
+		t.Errorf(`expected "foo" got "%v"`, target)
+	}
+	linkStr, _ := pack.ReadFileString("testdata/link")
+	if linkStr != "new foo" {
+		t.Errorf(`expected "new foo" got "%v"`, linkStr)
+	}
+}
@@ -25,16 +25,30 @@ package zgok
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
-	"io/ioutil"
+	"hash/crc32"
+	"html/template"
+	"io"
 	"net/http"
 	"os"
+	"os/exec"
+	pathpkg "path"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 )
 
+const (
+	MAX_SYMLINK_HOPS = 40 // Max number of symlinks followed on path resolution.
+)
+
+const (
+	METADATA_BUILD_TIME = "zgok.buildTime" // Metadata key of the build time. (RFC 3339)
+)
+
 const (
 	APP   = "zgok" // Application name.
 	MAJOR = 0      // Major version.
@@ -56,18 +70,35 @@ type FileSystem interface {
 	ReadFileString(path string) (string, error)
 	Paths() []string
 	SubFileSystem(rootPath string) (FileSystem, error)
+	Mount(layer FileSystem)
+	LayerOf(path string) (int, error)
+	ExtractTo(dir string, filter func(path string) bool, policy ExtractPolicy) error
+	ExtractTemp(filter func(path string) bool) (string, error)
+	Materialize(path string) (string, error)
+	Command(path string, args ...string) (*exec.Cmd, error)
 	Signature() Signature
 	SetSignature(signature Signature)
+	Metadata() map[string]string
+	SetMetadata(metadata map[string]string)
+	BuildTime() time.Time
+	AssetManifest() (map[string]string, error)
+	AssetFunc(urlPrefix string) func(name string) (string, error)
+	Integrity(path, algorithm string) (string, error)
+	IntegrityFuncs(urlPrefix, algorithm string) template.FuncMap
 	String() string
-	Open(name string) (http.File, error)     // Implements [net/http.FileSystem.Open]
-	FileServer(basePath string) http.Handler // Get a static file server.
+	Open(name string) (http.File, error)                              // Implements [net/http.FileSystem.Open]
+	FileServer(basePath string, options ...ServerOption) http.Handler // Get a static file server.
+	Router(mounts ...Mount) (http.Handler, error)                     // Get a router of the file servers.
 }
 
 // Zgok file system.
 type zgokFileSystem struct {
-	signature Signature       // Zgok signature.
-	rootPath  string          // Root path of the file system.
-	fileMap   map[string]File // Map of files.
+	signature Signature         // Zgok signature.
+	rootPath  string            // Root path of the file system.
+	fileMap   map[string]File   // Map of files.
+	metadata  map[string]string // Metadata of the payload.
+	origins   map[string]int    // Layer indexes of the mounted files.
+	topLayer  int               // Index of the top layer.
 }
 
 // Create a new file system.
@@ -76,27 +107,70 @@ func NewFileSystem() FileSystem {
 		signature: nil,
 		rootPath:  APP,
 		fileMap:   make(map[string]File),
+		metadata:  make(map[string]string),
+	}
+}
+
+// Restore option.
+type RestoreOption func(u *Unzipper)
+
+// Restore with the resource limits.
+func WithLimits(limits Limits) RestoreOption {
+	return func(u *Unzipper) {
+		u.SetLimits(limits)
+	}
+}
+
+// Restore keeping the raw deflate streams to serve as gzip.
+func WithDeflateStreams() RestoreOption {
+	return func(u *Unzipper) {
+		u.SetKeepDeflate(true)
+	}
+}
+
+// Restore skipping the entries with the unsafe names.
+// The handler is called with the error of every skipped entry.
+func WithQuarantine(handler func(err error)) RestoreOption {
+	return func(u *Unzipper) {
+		u.SetUnsafePathPolicy(UNSAFE_PATH_QUARANTINE)
+		u.SetQuarantineHandler(handler)
 	}
 }
 
 // Restore file system.
-func RestoreFileSystem(path string) (FileSystem, error) {
-	// Get bytes of exe file.
-	exeBytes, err := ioutil.ReadFile(path)
+func RestoreFileSystem(path string, options ...RestoreOption) (FileSystem, error) {
+	// Open exe file.
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return RestoreFromReaderAt(file, fileInfo.Size(), options...)
+}
+
+// Restore file system from bytes.
+func RestoreFromBytes(data []byte, options ...RestoreOption) (FileSystem, error) {
+	return RestoreFromReaderAt(bytes.NewReader(data), int64(len(data)), options...)
+}
+
+// Restore file system from the reader of the given size.
+// The data ends with the zip section and the signature.
+func RestoreFromReaderAt(r io.ReaderAt, size int64, options ...RestoreOption) (FileSystem, error) {
 	// Restore signature.
-	sigOffset := len(exeBytes) - SIGNATURE_BYTE_SIZE
-	sigBytes := exeBytes[sigOffset:]
-	signature, err := RestoreSignature(sigBytes)
+	signature, err := restoreSignatureAt(r, size)
 	if err != nil {
 		return nil, err
 	}
 	// Unzip zip section.
-	zipOffset := signature.ExeSize()
-	zipBytes := exeBytes[zipOffset:sigOffset]
-	unzipper := NewUnzipper(&zipBytes)
+	zipReader := io.NewSectionReader(r, signature.ExeSize(), signature.ZipSize())
+	unzipper := NewUnzipperReaderAt(zipReader, signature.ZipSize())
+	for _, option := range options {
+		option(unzipper)
+	}
 	zfs, err := unzipper.Unzip()
 	if err != nil {
 		return nil, err
@@ -106,6 +180,28 @@ func RestoreFileSystem(path string) (FileSystem, error) {
 	return zfs, nil
 }
 
+// Restore signature at the end of the reader of the given size.
+func restoreSignatureAt(r io.ReaderAt, size int64) (Signature, error) {
+	if size < SIGNATURE_BYTE_SIZE {
+		return nil, fmt.Errorf("signature not found")
+	}
+	sigBytes := make([]byte, SIGNATURE_BYTE_SIZE)
+	_, err := r.ReadAt(sigBytes, size-SIGNATURE_BYTE_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := RestoreSignature(sigBytes)
+	if err != nil {
+		return nil, err
+	}
+	// Check sizes.
+	if signature.TotalSize() != size {
+		return nil, fmt.Errorf("size mismatch: signature [%d] got [%d]",
+			signature.TotalSize(), size)
+	}
+	return signature, nil
+}
+
 // Add file to file system.
 func (zfs *zgokFileSystem) AddFile(file File) {
 	key := filepath.ToSlash(file.Path())
@@ -113,13 +209,60 @@ func (zfs *zgokFileSystem) AddFile(file File) {
 }
 
 // Get file from file system.
+// Symlinks in the path are resolved in the file system.
 func (zfs *zgokFileSystem) GetFile(path string) (File, error) {
+	_, file, err := zfs.getFile(path)
+	return file, err
+}
+
+// Get file and its resolved key from file system.
+func (zfs *zgokFileSystem) getFile(path string) (string, File, error) {
 	key := filepath.ToSlash(filepath.Join(zfs.rootPath, path))
+	key, err := zfs.resolveKey(key)
+	if err != nil {
+		return "", nil, err
+	}
 	file, exists := zfs.fileMap[key]
 	if !exists {
-		return nil, fmt.Errorf("file doesn't exist")
+		return "", nil, fmt.Errorf("file doesn't exist")
 	}
-	return file, nil
+	return key, file, nil
+}
+
+// Resolve symlinks in the key.
+func (zfs *zgokFileSystem) resolveKey(key string) (string, error) {
+	for hops := 0; hops <= MAX_SYMLINK_HOPS; hops++ {
+		// Return the key of the existing file.
+		file, exists := zfs.fileMap[key]
+		if exists && !isSymlink(file) {
+			return key, nil
+		}
+		// Replace the first symlink in the key with its target.
+		parts := strings.Split(key, "/")
+		replaced := false
+		for i := 1; i <= len(parts); i++ {
+			linkKey := strings.Join(parts[:i], "/")
+			link, exists := zfs.fileMap[linkKey]
+			if !exists || !isSymlink(link) {
+				continue
+			}
+			target := string(link.Bytes())
+			rest := strings.Join(parts[i:], "/")
+			key = pathpkg.Join(pathpkg.Dir(linkKey), target, rest)
+			replaced = true
+			break
+		}
+		if !replaced {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
+
+// Check if the file is a symlink.
+func isSymlink(file File) bool {
+	fileInfo := file.FileInfo()
+	return fileInfo != nil && fileInfo.Mode()&os.ModeSymlink != 0
 }
 
 // Get the content of file in bytes from file system.
@@ -128,6 +271,9 @@ func (zfs *zgokFileSystem) ReadFile(path string) ([]byte, error) {
 	if err != nil {
 		return []byte{}, err
 	}
+	if file.FileInfo() != nil && file.FileInfo().IsDir() {
+		return []byte{}, fmt.Errorf("is a directory")
+	}
 	return file.Bytes(), nil
 }
 
@@ -145,7 +291,10 @@ func (zfs *zgokFileSystem) ReadFileString(path string) (string, error) {
 func (zfs *zgokFileSystem) Paths() []string {
 	paths := []string{}
 	prefix := zfs.rootPath + "/"
-	for key := range zfs.fileMap {
+	for key, file := range zfs.fileMap {
+		if file.FileInfo() != nil && file.FileInfo().IsDir() {
+			continue
+		}
 		if strings.HasPrefix(key, prefix) {
 			relPath := strings.TrimPrefix(key, prefix)
 			paths = append(paths, relPath)
@@ -167,13 +316,25 @@ func (zfs *zgokFileSystem) SubFileSystem(rootPath string) (FileSystem, error) {
 		signature: zfs.signature,
 		rootPath:  newRootPath,
 		fileMap:   make(map[string]File),
+		metadata:  zfs.metadata,
+		origins:   make(map[string]int),
+		topLayer:  zfs.topLayer,
 	}
 	// Add all the sets matching the new root path.
 	for key, value := range zfs.fileMap {
-		if key[0:len(newRootPath)] == newRootPath {
+		if key == newRootPath || strings.HasPrefix(key, newRootPath+"/") {
 			subFs.fileMap[key] = value
 		}
 	}
+	// Share the asset manifest outside the file tree.
+	if manifest, exists := zfs.fileMap[MANIFEST_PATH]; exists {
+		subFs.fileMap[MANIFEST_PATH] = manifest
+	}
+	for key, layer := range zfs.origins {
+		if key == newRootPath || strings.HasPrefix(key, newRootPath+"/") {
+			subFs.origins[key] = layer
+		}
+	}
 	return subFs, nil
 }
 
@@ -187,6 +348,26 @@ func (zfs *zgokFileSystem) SetSignature(signature Signature) {
 	zfs.signature = signature
 }
 
+// Get the build time recorded in the metadata.
+// Returns the zero time if it is not recorded.
+func (zfs *zgokFileSystem) BuildTime() time.Time {
+	buildTime, err := time.Parse(time.RFC3339, zfs.metadata[METADATA_BUILD_TIME])
+	if err != nil {
+		return time.Time{}
+	}
+	return buildTime
+}
+
+// Get metadata of the payload.
+func (zfs *zgokFileSystem) Metadata() map[string]string {
+	return zfs.metadata
+}
+
+// Set metadata of the payload.
+func (zfs *zgokFileSystem) SetMetadata(metadata map[string]string) {
+	zfs.metadata = metadata
+}
+
 // Get string.
 func (zfs *zgokFileSystem) String() string {
 	return zfs.Signature().String()
@@ -195,21 +376,64 @@ func (zfs *zgokFileSystem) String() string {
 // Open the file.
 // Implements [net/http.FileSystem.Open]
 func (zfs *zgokFileSystem) Open(name string) (http.File, error) {
-	path := strings.TrimLeft(name, "/")
-	file, err := zfs.GetFile(path)
+	path := strings.Trim(name, "/")
+	key, file, err := zfs.getFile(path)
 	if err != nil {
-		// Return an abstract directory.
-		dir := &zgokFile{
-			fileInfo: zgokFileInfo{
-				name: filepath.Base(path),
-				mode: os.ModeDir | os.ModePerm,
-			},
+		// Return the directory implied by the paths of the files.
+		key = filepath.ToSlash(filepath.Join(zfs.rootPath, path))
+		if !zfs.hasChildren(key) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
 		}
-		return dir, nil
+		file = newDirFile(key)
 	}
-	// Set a new file reader
-	file.SetNewReader()
-	return file, nil
+	// Open a new handle of the file.
+	handle := openFile(file)
+	if file.FileInfo().IsDir() {
+		if zf, ok := handle.(*zgokFile); ok {
+			zf.entries = zfs.readDir(key)
+		}
+	}
+	return handle, nil
+}
+
+// Check if the key has any child in the file system.
+func (zfs *zgokFileSystem) hasChildren(key string) bool {
+	prefix := key + "/"
+	for fileKey := range zfs.fileMap {
+		if strings.HasPrefix(fileKey, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the file information of the children of the directory.
+func (zfs *zgokFileSystem) readDir(key string) []os.FileInfo {
+	prefix := key + "/"
+	infoMap := make(map[string]os.FileInfo)
+	for fileKey, file := range zfs.fileMap {
+		if !strings.HasPrefix(fileKey, prefix) {
+			continue
+		}
+		relPath := strings.TrimPrefix(fileKey, prefix)
+		if pos := strings.Index(relPath, "/"); pos >= 0 {
+			// Add the directory implied by the descendant.
+			name := relPath[:pos]
+			if _, exists := infoMap[name]; !exists {
+				infoMap[name] = newDirFile(prefix + name).FileInfo()
+			}
+			continue
+		}
+		infoMap[relPath] = file.FileInfo()
+	}
+	entries := make([]os.FileInfo, 0, len(infoMap))
+	for _, fileInfo := range infoMap {
+		entries = append(entries, fileInfo)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
 }
 
 // File interface.
@@ -220,6 +444,16 @@ type File interface {
 	FileInfo() os.FileInfo                        // Get file info.
 	SetBytes(content []byte)                      // Set content bytes.
 	Bytes() []byte                                // Get content bytes.
+	SetHash(hash []byte)                          // Set SHA-256 of the content.
+	Hash() []byte                                 // Get SHA-256 of the content.
+	SetDigest(algorithm string, digest []byte)    // Set digest of the content by the SRI algorithm.
+	Digest(algorithm string) []byte               // Get digest of the content by the SRI algorithm.
+	SetContentType(ctype string)                  // Set content type.
+	ContentType() string                          // Get content type.
+	SetCRC32(crc uint32)                          // Set CRC-32 of the content.
+	CRC32() uint32                                // Get CRC-32 of the content.
+	SetDeflate(raw []byte)                        // Set raw deflate stream of the content.
+	Deflate() []byte                              // Get raw deflate stream of the content.
 	SetNewReader()                                // Set a new reader.
 	Close() error                                 // Implements [net/http.File.Close]
 	Read(p []byte) (int, error)                   // Implements [net/http.File.Read]
@@ -234,6 +468,14 @@ type zgokFile struct {
 	fileInfo os.FileInfo   // File info.
 	content  []byte        // Content of the file.
 	reader   *bytes.Reader // File reader.
+	entries  []os.FileInfo // Directory entries not read yet.
+	hash     []byte        // SHA-256 of the content.
+	sha384   []byte        // SHA-384 of the content.
+	sha512   []byte        // SHA-512 of the content.
+	ctype    string        // Content type.
+	crc      uint32        // CRC-32 of the content.
+	hasCRC   bool          // Is CRC-32 set?
+	deflate  []byte        // Raw deflate stream of the content.
 }
 
 // Create a new zgok file.
@@ -241,6 +483,41 @@ func NewZgokFile() File {
 	return &zgokFile{}
 }
 
+// Create a directory without an entry in the payload.
+func newDirFile(path string) File {
+	dir := NewZgokFile()
+	dir.SetPath(path)
+	dir.SetFileInfo(zgokFileInfo{
+		name: pathpkg.Base(dir.Path()),
+		mode: os.ModeDir | 0755,
+	})
+	return dir
+}
+
+// Open a new handle of the file.
+// Handles share the content but have their own readers.
+func openFile(file File) File {
+	zf, ok := file.(*zgokFile)
+	if !ok {
+		file.SetNewReader()
+		return file
+	}
+	handle := &zgokFile{
+		path:     zf.path,
+		fileInfo: zf.fileInfo,
+		content:  zf.content,
+		hash:     zf.hash,
+		sha384:   zf.sha384,
+		sha512:   zf.sha512,
+		ctype:    zf.ctype,
+		crc:      zf.crc,
+		hasCRC:   zf.hasCRC,
+		deflate:  zf.deflate,
+	}
+	handle.SetNewReader()
+	return handle
+}
+
 // Set file path.
 func (zf *zgokFile) SetPath(path string) {
 	zf.path = strings.Replace(path, `\`, "/", -1)
@@ -262,8 +539,15 @@ func (zf *zgokFile) FileInfo() os.FileInfo {
 }
 
 // Set content bytes.
+// The digests of the previous content are cleared.
 func (zf *zgokFile) SetBytes(content []byte) {
 	zf.content = content
+	zf.hash = nil
+	zf.sha384 = nil
+	zf.sha512 = nil
+	zf.ctype = ""
+	zf.hasCRC = false
+	zf.deflate = nil
 }
 
 // Get content bytes.
@@ -271,6 +555,96 @@ func (zf *zgokFile) Bytes() []byte {
 	return zf.content
 }
 
+// Set SHA-256 of the content.
+func (zf *zgokFile) SetHash(hash []byte) {
+	zf.hash = hash
+}
+
+// Get SHA-256 of the content.
+// Calculated from the content if not recorded in the payload.
+func (zf *zgokFile) Hash() []byte {
+	if zf.hash != nil {
+		return zf.hash
+	}
+	sum := sha256.Sum256(zf.content)
+	return sum[:]
+}
+
+// Set digest of the content by the SRI algorithm.
+func (zf *zgokFile) SetDigest(algorithm string, digest []byte) {
+	switch algorithm {
+	case SRI_SHA256:
+		zf.hash = digest
+	case SRI_SHA384:
+		zf.sha384 = digest
+	case SRI_SHA512:
+		zf.sha512 = digest
+	}
+}
+
+// Get digest of the content by the SRI algorithm.
+// Calculated from the content if not recorded in the payload.
+// Returns nil for the unknown algorithms.
+func (zf *zgokFile) Digest(algorithm string) []byte {
+	switch algorithm {
+	case SRI_SHA256:
+		return zf.Hash()
+	case SRI_SHA384:
+		if zf.sha384 != nil {
+			return zf.sha384
+		}
+		sum := sha512.Sum384(zf.content)
+		return sum[:]
+	case SRI_SHA512:
+		if zf.sha512 != nil {
+			return zf.sha512
+		}
+		sum := sha512.Sum512(zf.content)
+		return sum[:]
+	}
+	return nil
+}
+
+// Set content type.
+func (zf *zgokFile) SetContentType(ctype string) {
+	zf.ctype = ctype
+}
+
+// Get content type.
+// Detected from the path and the content if not recorded in the payload.
+func (zf *zgokFile) ContentType() string {
+	if zf.ctype != "" {
+		return zf.ctype
+	}
+	return detectContentType(zf.path, zf.content)
+}
+
+// Set CRC-32 of the content.
+func (zf *zgokFile) SetCRC32(crc uint32) {
+	zf.crc = crc
+	zf.hasCRC = true
+}
+
+// Get CRC-32 of the content.
+// Calculated from the content if not recorded in the payload.
+func (zf *zgokFile) CRC32() uint32 {
+	if zf.hasCRC {
+		return zf.crc
+	}
+	return crc32.ChecksumIEEE(zf.content)
+}
+
+// Set raw deflate stream of the content.
+func (zf *zgokFile) SetDeflate(raw []byte) {
+	zf.deflate = raw
+}
+
+// Get raw deflate stream of the content.
+// Returns nil if the content is not compressed in the payload.
+func (zf *zgokFile) Deflate() []byte {
+	return zf.deflate
+}
+
 // Set a new reader.
 func (zf *zgokFile) SetNewReader() {
 	reader := bytes.NewReader(zf.content)
@@ -286,13 +660,31 @@ func (zf *zgokFile) Close() error {
 // Read file.
 // Implements [net/http.File.Read]
 func (zf *zgokFile) Read(p []byte) (int, error) {
+	if zf.fileInfo != nil && zf.fileInfo.IsDir() {
+		return 0, fmt.Errorf("is a directory")
+	}
 	return zf.reader.Read(p)
 }
 
 // Read directories.
 // Implements [net/http.File.Readdir]
 func (zf *zgokFile) Readdir(count int) ([]os.FileInfo, error) {
-	return nil, fmt.Errorf(`not allowed to "Readdir()"`)
+	if zf.fileInfo == nil || !zf.fileInfo.IsDir() {
+		return nil, fmt.Errorf("not a directory")
+	}
+	entries := zf.entries
+	if count <= 0 {
+		zf.entries = nil
+		return entries, nil
+	}
+	if len(entries) == 0 {
+		return entries, io.EOF
+	}
+	if count < len(entries) {
+		entries = entries[:count]
+	}
+	zf.entries = zf.entries[len(entries):]
+	return entries, nil
 }
 
 // Seek file.
@@ -1,7 +1,16 @@
 package zgok
 
 import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestZipUnzip(t *testing.T) {
@@ -72,3 +81,301 @@ func TestZipUnzip(t *testing.T) {
 		t.Errorf("Empty directory [testdata/empty] is not ignored.")
 	}
 }
+
+// Create a directory with symlinks for testing.
+//
+//	root/file
+//	root/dir/bar
+//	root/link_file -> file
+//	root/link_dir -> dir
+func createSymlinkDir(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks are not tested on windows.")
+	}
+	root, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	os.Mkdir(fpath.Join(root, "dir"), 0755)
+	ioutil.WriteFile(fpath.Join(root, "file"), []byte("file"), 0644)
+	ioutil.WriteFile(fpath.Join(root, "dir", "bar"), []byte("bar"), 0644)
+	os.Symlink("file", fpath.Join(root, "link_file"))
+	os.Symlink("dir", fpath.Join(root, "link_dir"))
+	return root
+}
+
+func TestZipSymlinkFollow(t *testing.T) {
+	root := createSymlinkDir(t)
+	defer os.RemoveAll(root)
+	zipper := NewZipper()
+	err := zipper.AddAs(root, "root")
+	if err != nil {
+		t.Fatalf("AddAs():error=[%v]", err)
+	}
+	zipper.Close()
+	bytes, _ := zipper.Bytes()
+	zfs, err := NewUnzipper(&bytes).Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	barStr, err := zfs.ReadFileString("root/link_dir/bar")
+	if err != nil || barStr != "bar" {
+		t.Errorf("[root/link_dir/bar]: expected [bar] got [%s] error [%v]", barStr, err)
+	}
+	fileStr, err := zfs.ReadFileString("root/link_file")
+	if err != nil || fileStr != "file" {
+		t.Errorf("[root/link_file]: expected [file] got [%s] error [%v]", fileStr, err)
+	}
+	// Detect symlink loop.
+	os.Symlink(".", fpath.Join(root, "dir", "loop"))
+	zipper = NewZipper()
+	err = zipper.AddAs(root, "root")
+	if err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("Expected symlink loop error got [%v].", err)
+	}
+}
+
+func TestZipSymlinkPreserve(t *testing.T) {
+	root := createSymlinkDir(t)
+	defer os.RemoveAll(root)
+	zipper := NewZipper()
+	zipper.SetSymlinkMode(SYMLINK_PRESERVE)
+	err := zipper.AddAs(root, "root")
+	if err != nil {
+		t.Fatalf("AddAs():error=[%v]", err)
+	}
+	zipper.Close()
+	bytes, _ := zipper.Bytes()
+	zfs, err := NewUnzipper(&bytes).Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	// Symlinks are stored as symlink entries.
+	expectedPaths := []string{
+		"root/dir/bar",
+		"root/file",
+		"root/link_dir",
+		"root/link_file",
+	}
+	paths := zfs.Paths()
+	if strings.Join(paths, ",") != strings.Join(expectedPaths, ",") {
+		t.Errorf("Paths(): expected [%v] got [%v]", expectedPaths, paths)
+	}
+	// Symlinks are resolved in the file system.
+	barStr, err := zfs.ReadFileString("root/link_dir/bar")
+	if err != nil || barStr != "bar" {
+		t.Errorf("[root/link_dir/bar]: expected [bar] got [%s] error [%v]", barStr, err)
+	}
+	fileStr, err := zfs.ReadFileString("root/link_file")
+	if err != nil || fileStr != "file" {
+		t.Errorf("[root/link_file]: expected [file] got [%s] error [%v]", fileStr, err)
+	}
+	// Reject symlinks pointing out of zip.
+	os.Symlink("../../../outside", fpath.Join(root, "dir", "out"))
+	zipper = NewZipper()
+	zipper.SetSymlinkMode(SYMLINK_PRESERVE)
+	if err := zipper.AddAs(root, "root"); err == nil {
+		t.Errorf("Expected error on symlink out of zip.")
+	}
+}
+
+func TestZipSymlinkReject(t *testing.T) {
+	root := createSymlinkDir(t)
+	defer os.RemoveAll(root)
+	zipper := NewZipper()
+	zipper.SetSymlinkMode(SYMLINK_REJECT)
+	if err := zipper.AddAs(root, "root"); err == nil {
+		t.Errorf("Expected error on symlink.")
+	}
+}
+
+func TestZipDirectoryEntries(t *testing.T) {
+	// Create a directory with an executable file.
+	root, err := ioutil.TempDir("", "zgok")
+	if err != nil {
+		t.Fatalf("TempDir():error=[%v]", err)
+	}
+	defer os.RemoveAll(root)
+	os.Mkdir(fpath.Join(root, "bin"), 0750)
+	ioutil.WriteFile(fpath.Join(root, "bin", "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	modTime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+	os.Chtimes(fpath.Join(root, "bin", "run.sh"), modTime, modTime)
+	os.Chtimes(fpath.Join(root, "bin"), modTime, modTime)
+	// Zip and unzip.
+	zipper := NewZipper()
+	err = zipper.AddAs(root, "root")
+	if err != nil {
+		t.Fatalf("AddAs():error=[%v]", err)
+	}
+	zipper.Close()
+	bytes, _ := zipper.Bytes()
+	zfs, err := NewUnzipper(&bytes).Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	// Directories are not listed in paths.
+	paths := zfs.Paths()
+	if len(paths) != 1 || paths[0] != "root/bin/run.sh" {
+		t.Errorf("Paths(): expected [root/bin/run.sh] got [%v]", paths)
+	}
+	// Verify file info of the file.
+	file, err := zfs.GetFile("root/bin/run.sh")
+	if err != nil {
+		t.Fatalf("GetFile():error=[%v]", err)
+	}
+	if runtime.GOOS != "windows" && file.FileInfo().Mode().Perm() != 0755 {
+		t.Errorf("Mode(): expected [%v] got [%v]", os.FileMode(0755), file.FileInfo().Mode())
+	}
+	if !file.FileInfo().ModTime().Equal(modTime) {
+		t.Errorf("ModTime(): expected [%v] got [%v]", modTime, file.FileInfo().ModTime())
+	}
+	// Verify file info of the directory.
+	dir, err := zfs.Open("/root/bin")
+	if err != nil {
+		t.Fatalf("Open():error=[%v]", err)
+	}
+	dirInfo, _ := dir.Stat()
+	if !dirInfo.IsDir() || !dirInfo.ModTime().Equal(modTime) {
+		t.Errorf("Stat(): unexpected directory info [%v] [%v]", dirInfo.Mode(), dirInfo.ModTime())
+	}
+	if runtime.GOOS != "windows" && dirInfo.Mode().Perm() != 0750 {
+		t.Errorf("Mode(): expected [%v] got [%v]", os.FileMode(0750), dirInfo.Mode().Perm())
+	}
+	entries, err := dir.Readdir(-1)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "run.sh" {
+		t.Errorf("Readdir(): unexpected [%v] error [%v]", entries, err)
+	}
+	// Verify directory implied by the paths.
+	dir, err = zfs.Open("/")
+	if err != nil {
+		t.Fatalf("Open():error=[%v]", err)
+	}
+	entries, err = dir.Readdir(1)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "root" {
+		t.Errorf("Readdir(): unexpected [%v] error [%v]", entries, err)
+	}
+	if _, err = dir.Readdir(1); err != io.EOF {
+		t.Errorf("Readdir(): expected EOF got [%v]", err)
+	}
+	// Missing paths do not exist.
+	if _, err = zfs.Open("/missing"); !os.IsNotExist(err) {
+		t.Errorf("Open(): expected not exist error got [%v]", err)
+	}
+}
+
+// Create zip bytes with the entry names for testing.
+func createZipBytes(t testing.TB, names ...string) []byte {
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	for _, name := range names {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Create():error=[%v]", err)
+		}
+		w.Write([]byte(name))
+	}
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestUnzipUnsafePaths(t *testing.T) {
+	unsafeNames := []string{
+		"../evil",
+		"zgok/../../evil",
+		"/etc/passwd",
+		`zgok\..\evil`,
+		"C:/evil",
+		"zgok/a\x00b",
+		"",
+		"./",
+	}
+	for _, name := range unsafeNames {
+		zipBytes := createZipBytes(t, "zgok/foo", name)
+		_, err := NewUnzipper(&zipBytes).Unzip()
+		if _, ok := err.(*UnsafePathError); !ok {
+			t.Errorf("[%q]: expected unsafe path error got [%v]", name, err)
+		}
+	}
+	// Detect duplicates after normalization.
+	zipBytes := createZipBytes(t, "zgok/foo", "zgok/./foo")
+	_, err := NewUnzipper(&zipBytes).Unzip()
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Expected duplicate entry error got [%v]", err)
+	}
+	// Quarantine unsafe entries.
+	zipBytes = createZipBytes(t, "zgok/foo", "../evil", "zgok//bar")
+	unzipper := NewUnzipper(&zipBytes)
+	unzipper.SetUnsafePathPolicy(UNSAFE_PATH_QUARANTINE)
+	zfs, err := unzipper.Unzip()
+	if err != nil {
+		t.Fatalf("Unzip():error=[%v]", err)
+	}
+	if len(unzipper.Quarantined()) != 1 {
+		t.Errorf("Quarantined(): unexpected [%v]", unzipper.Quarantined())
+	}
+	paths := zfs.Paths()
+	if strings.Join(paths, ",") != "bar,foo" {
+		t.Errorf("Paths(): expected [bar foo] got [%v]", paths)
+	}
+	// Report quarantined entries on restoring.
+	signature := NewSignature()
+	signature.SetZipSize(int64(len(zipBytes)))
+	sigBytes, _ := signature.Dump()
+	var quarantined []error
+	zfs, err = RestoreFromBytes(append(zipBytes, sigBytes...),
+		WithQuarantine(func(err error) { quarantined = append(quarantined, err) }))
+	if err != nil {
+		t.Fatalf("RestoreFromBytes():error=[%v]", err)
+	}
+	if len(quarantined) != 1 || !strings.Contains(quarantined[0].Error(), "../evil") {
+		t.Errorf("WithQuarantine(): unexpected [%v]", quarantined)
+	}
+	if strings.Join(zfs.Paths(), ",") != "bar,foo" {
+		t.Errorf("Paths(): expected [bar foo] got [%v]", zfs.Paths())
+	}
+}
+
+func TestUnzipLimits(t *testing.T) {
+	// Create zip with a highly compressed file.
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	w, _ := writer.Create("zgok/zeros")
+	w.Write(make([]byte, 1<<20))
+	w, _ = writer.Create("zgok/foo")
+	w.Write([]byte("foo"))
+	writer.Close()
+	zipBytes := buf.Bytes()
+	tests := []struct {
+		limits Limits
+		kind   LimitKind
+	}{
+		{Limits{MaxEntries: 1}, LIMIT_ENTRIES},
+		{Limits{MaxFileSize: 1 << 19}, LIMIT_FILE_SIZE},
+		{Limits{MaxTotalSize: 1<<20 + 2}, LIMIT_TOTAL_SIZE},
+		{Limits{MaxRatio: 100}, LIMIT_RATIO},
+	}
+	for _, test := range tests {
+		unzipper := NewUnzipper(&zipBytes)
+		unzipper.SetLimits(test.limits)
+		_, err := unzipper.Unzip()
+		limitErr, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("[%v]: expected limit error got [%v]", test.limits, err)
+			continue
+		}
+		if limitErr.Kind != test.kind {
+			t.Errorf("[%v]: expected [%v] got [%v]", test.limits, test.kind, limitErr.Kind)
+		}
+	}
+	// Unzip within the limits.
+	unzipper := NewUnzipper(&zipBytes)
+	unzipper.SetLimits(Limits{
+		MaxEntries:   2,
+		MaxFileSize:  1 << 20,
+		MaxTotalSize: 1<<20 + 3,
+		MaxRatio:     2000,
+	})
+	if _, err := unzipper.Unzip(); err != nil {
+		t.Errorf("Unzip():error=[%v]", err)
+	}
+}
@@ -3,17 +3,71 @@ package zgok
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	HASH_EXTRA_ID         uint16 = 0x677a // Header ID of the extra field holding SHA-256. ("zg")
+	INTEGRITY_EXTRA_ID    uint16 = 0x697a // Header ID of the extra field holding SHA-384 and SHA-512. ("zi")
+	CONTENT_TYPE_EXTRA_ID uint16 = 0x747a // Header ID of the extra field holding the content type. ("zt")
 )
 
 type Zipper struct {
-	isClosed bool          // Is the zip file closed?
-	buffer   *bytes.Buffer // Buffer.
-	writer   *zip.Writer   // Zip writer.
-	basePath string        // Base path.
+	isClosed     bool              // Is the zip file closed?
+	buffer       *bytes.Buffer     // Buffer.
+	writer       *zip.Writer       // Zip writer.
+	basePath     string            // Base path.
+	method       uint16            // Compression method.
+	excludes     []string          // Glob patterns of the excluded paths.
+	metadata     map[string]string // Metadata written in the zip comment.
+	symlink      SymlinkMode       // Symlink handling mode.
+	names        map[string]bool   // Names of the entries.
+	gzipPatterns []string          // Glob patterns of the files to precompress.
+	gzipTargets  []gzipTarget      // Files to add the precompressed siblings.
+	fpPatterns   []string          // Glob patterns of the files to fingerprint.
+	fpPaths      map[string]bool   // Paths to fingerprint in the added manifests.
+	manifest     map[string]string // Fingerprinted paths by the logical paths.
+}
+
+// File to add the precompressed sibling.
+type gzipTarget struct {
+	header  *zip.FileHeader // Header of the original file.
+	content []byte          // Content of the original file.
+}
+
+// Symlink handling mode.
+type SymlinkMode int
+
+const (
+	SYMLINK_FOLLOW   SymlinkMode = iota // Follow symlinks with loop detection.
+	SYMLINK_PRESERVE                    // Preserve symlinks as symlink entries.
+	SYMLINK_REJECT                      // Reject symlinks with an error.
+)
+
+// Parse symlink mode name. ("follow", "preserve" or "reject")
+// Empty name means "follow".
+func ParseSymlinkMode(name string) (SymlinkMode, error) {
+	switch strings.ToLower(name) {
+	case "", "follow":
+		return SYMLINK_FOLLOW, nil
+	case "preserve":
+		return SYMLINK_PRESERVE, nil
+	case "reject":
+		return SYMLINK_REJECT, nil
+	}
+	return SYMLINK_FOLLOW, fmt.Errorf("unknown symlink mode [%s]", name)
 }
 
 // Create new zipper.
@@ -21,37 +75,170 @@ func NewZipper() *Zipper {
 	z := &Zipper{}
 	z.isClosed = false
 	z.basePath = "zgok"
+	z.method = zip.Deflate
+	z.names = make(map[string]bool)
+	z.fpPaths = make(map[string]bool)
+	z.manifest = make(map[string]string)
 	z.buffer = new(bytes.Buffer)
 	z.writer = zip.NewWriter(z.buffer)
 	return z
 }
 
+// Set compression method. (zip.Deflate or zip.Store)
+func (z *Zipper) SetMethod(method uint16) error {
+	if method != zip.Deflate && method != zip.Store {
+		return fmt.Errorf("unsupported compression method [%d]", method)
+	}
+	z.method = method
+	return nil
+}
+
+// Set symlink handling mode.
+func (z *Zipper) SetSymlinkMode(mode SymlinkMode) {
+	z.symlink = mode
+}
+
+// Add glob patterns of the paths to exclude.
+func (z *Zipper) AddExcludes(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	z.excludes = append(z.excludes, patterns...)
+	return nil
+}
+
+// Set metadata.
+func (z *Zipper) SetMetadata(metadata map[string]string) {
+	z.metadata = metadata
+}
+
+// Set glob patterns of the files to add the gzip precompressed siblings.
+// Siblings (ex. "app.js.gz") are not added if they already exist or they
+// are not smaller than the original files.
+func (z *Zipper) SetPrecompress(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	z.gzipPatterns = patterns
+	return nil
+}
+
 // Add files in the path to zip.
 func (z *Zipper) Add(path string) error {
+	return z.AddAs(path, "")
+}
+
+// Add files in the path to zip as the destination path.
+// The source path is used when the destination path is empty.
+func (z *Zipper) AddAs(srcPath, destPath string, excludes ...string) error {
 	// Check if zip is closed or not.
 	if z.isClosed {
 		return fmt.Errorf("zip already closed")
 	}
-	// Get file information.
-	fileInfo, err := os.Stat(path)
+	// Check destination path.
+	if destPath == "" {
+		destPath = srcPath
+	} else {
+		var err error
+		destPath, err = cleanDestPath(destPath)
+		if err != nil {
+			return err
+		}
+	}
+	// Check exclude patterns.
+	if err := validatePatterns(excludes); err != nil {
+		return err
+	}
+	excludes = append(excludes, z.excludes...)
+	// Check if the path exists.
+	_, err := os.Lstat(srcPath)
 	if err != nil {
 		return err
 	}
-	// Determine if the path is a directory or not.
-	if fileInfo.IsDir() {
-		err = z.addDir(path)
-	} else {
-		err = z.addFile(path)
+	return z.addPath(srcPath, destPath, "", excludes, make(map[string]bool))
+}
+
+// Add all the entries in the file system to zip.
+// Paths in the file system are used as the destination paths.
+func (z *Zipper) AddFileSystem(fs FileSystem) error {
+	// Check if zip is closed or not.
+	if z.isClosed {
+		return fmt.Errorf("zip already closed")
 	}
+	entries := fileSystemEntries(fs)
+	// Fingerprint the paths in the manifest again with the current contents.
+	manifest, err := fs.AssetManifest()
 	if err != nil {
 		return err
 	}
+	for logical := range manifest {
+		z.fpPaths[logical] = true
+	}
+	for _, entry := range entries {
+		fileInfo := entry.file.FileInfo()
+		header, err := zip.FileInfoHeader(fileInfo)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(z.basePath, entry.relPath)
+		// Add directory entry.
+		if fileInfo.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+			if z.names[header.Name] {
+				continue
+			}
+			if _, err = z.createHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+		// Add file or symlink entry.
+		header.Method = z.method
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			header.Method = zip.Store
+		} else {
+			header.Extra = append(hashExtra(entry.file.Bytes()),
+				contentTypeExtra(entry.file.ContentType())...)
+			z.addGzipTarget(header, entry.file.Bytes())
+			z.addFingerprint(entry.relPath, entry.file.Bytes())
+		}
+		zipFile, err := z.createHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = zipFile.Write(entry.file.Bytes())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Close zip writer.
 func (z *Zipper) Close() error {
-	err := z.writer.Close()
+	// Write precompressed siblings.
+	err := z.addGzipSiblings()
+	if err != nil {
+		return err
+	}
+	// Write asset manifest.
+	err = z.addManifest()
+	if err != nil {
+		return err
+	}
+	// Write metadata.
+	if len(z.metadata) > 0 {
+		comment, err := json.Marshal(z.metadata)
+		if err != nil {
+			return err
+		}
+		err = z.writer.SetComment(string(comment))
+		if err != nil {
+			return err
+		}
+	}
+	err = z.writer.Close()
 	if err != nil {
 		return err
 	}
@@ -68,7 +255,7 @@ func (z *Zipper) Bytes() ([]byte, error) {
 }
 
 // Add file to zip.
-func (z *Zipper) addFile(filePath string) error {
+func (z *Zipper) addFile(filePath, destPath string) error {
 	// Get file information.
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -81,9 +268,14 @@ func (z *Zipper) addFile(filePath string) error {
 	}
 	// Set zip header.
 	header, _ := zip.FileInfoHeader(fileInfo)
-	path := filepath.Join(z.basePath, filePath)
-	header.Name = path
-	zipFile, err := z.writer.CreateHeader(header)
+	path := filepath.Join(z.basePath, destPath)
+	header.Name = filepath.ToSlash(path)
+	header.Method = z.method
+	header.Extra = append(hashExtra(content),
+		contentTypeExtra(detectContentType(destPath, content))...)
+	z.addGzipTarget(header, content)
+	z.addFingerprint(strings.TrimPrefix(header.Name, z.basePath+"/"), content)
+	zipFile, err := z.createHeader(header)
 	if err != nil {
 		return err
 	}
@@ -95,20 +287,274 @@ func (z *Zipper) addFile(filePath string) error {
 	return nil
 }
 
-// Add directory to zip.
-func (z *Zipper) addDir(dirPath string) error {
-	// Walk through all the files in the directory.
-	err := filepath.Walk(dirPath,
-		func(path string, info os.FileInfo, err error) error {
-			// Do nothing on directory.
-			if info.IsDir() {
-				return nil
-			}
-			// Add file to zip.
-			if err := z.addFile(path); err != nil {
-				return err
-			}
-			return nil
-		})
+// Add the file, the directory or the symlink in the path to zip.
+// The relative path is empty for the path given to "AddAs()".
+// The visited map holds the real paths of the ancestor directories
+// to detect symlink loops.
+func (z *Zipper) addPath(srcPath, destPath, relPath string,
+	excludes []string, visited map[string]bool) error {
+	// Get file information without following symlink.
+	fileInfo, err := os.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+	// Skip excluded paths.
+	if (relPath != "" || !fileInfo.IsDir()) &&
+		isExcluded(srcPath, relPath, excludes) {
+		return nil
+	}
+	// Handle symlink.
+	if fileInfo.Mode()&os.ModeSymlink != 0 {
+		switch z.symlink {
+		case SYMLINK_REJECT:
+			return fmt.Errorf("symlink not allowed [%s]", srcPath)
+		case SYMLINK_PRESERVE:
+			return z.addSymlink(srcPath, destPath)
+		}
+		fileInfo, err = os.Stat(srcPath)
+		if err != nil {
+			return fmt.Errorf("broken symlink [%s]: %v", srcPath, err)
+		}
+	}
+	// Add file to zip.
+	if !fileInfo.IsDir() {
+		return z.addFile(srcPath, destPath)
+	}
+	// Check symlink loop.
+	realPath, err := filepath.EvalSymlinks(srcPath)
+	if err != nil {
+		return err
+	}
+	realPath, err = filepath.Abs(realPath)
+	if err != nil {
+		return err
+	}
+	if visited[realPath] {
+		return fmt.Errorf("symlink loop detected [%s]", srcPath)
+	}
+	visited[realPath] = true
+	defer delete(visited, realPath)
+	// Add directory entry.
+	err = z.addDirEntry(fileInfo, destPath)
+	if err != nil {
+		return err
+	}
+	// Add all the entries in the directory.
+	dir, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err = z.addPath(filepath.Join(srcPath, name),
+			filepath.Join(destPath, name), filepath.Join(relPath, name),
+			excludes, visited)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add directory entry with its mode and modified time to zip.
+func (z *Zipper) addDirEntry(fileInfo os.FileInfo, destPath string) error {
+	header, err := zip.FileInfoHeader(fileInfo)
+	if err != nil {
+		return err
+	}
+	header.Name = path.Join(z.basePath, filepath.ToSlash(destPath)) + "/"
+	header.Method = zip.Store
+	// Skip the directory already added.
+	if z.names[header.Name] {
+		return nil
+	}
+	_, err = z.createHeader(header)
 	return err
 }
+
+// Add symlink to zip.
+// The link target is stored as the content.
+func (z *Zipper) addSymlink(linkPath, destPath string) error {
+	// Get file information.
+	fileInfo, err := os.Lstat(linkPath)
+	if err != nil {
+		return err
+	}
+	// Get link target.
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return err
+	}
+	target = filepath.ToSlash(target)
+	// Check if the target is in the zip.
+	zipPath := path.Join(z.basePath, filepath.ToSlash(destPath))
+	resolved := path.Join(path.Dir(zipPath), target)
+	if path.IsAbs(target) || filepath.IsAbs(target) ||
+		!strings.HasPrefix(resolved+"/", z.basePath+"/") {
+		return fmt.Errorf("symlink target out of zip [%s -> %s]", linkPath, target)
+	}
+	// Set zip header.
+	header, _ := zip.FileInfoHeader(fileInfo)
+	header.Name = zipPath
+	header.Method = zip.Store
+	zipFile, err := z.createHeader(header)
+	if err != nil {
+		return err
+	}
+	// Write link target.
+	_, err = zipFile.Write([]byte(target))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Add the file to the targets of the precompressed siblings.
+func (z *Zipper) addGzipTarget(header *zip.FileHeader, content []byte) {
+	relPath := strings.TrimPrefix(header.Name, z.basePath+"/")
+	if len(z.gzipPatterns) == 0 || !isExcluded(relPath, "", z.gzipPatterns) {
+		return
+	}
+	z.gzipTargets = append(z.gzipTargets, gzipTarget{header: header, content: content})
+}
+
+// Add the gzip precompressed siblings of the targets.
+func (z *Zipper) addGzipSiblings() error {
+	for _, target := range z.gzipTargets {
+		name := target.header.Name + ".gz"
+		if z.names[name] {
+			continue
+		}
+		buf := new(bytes.Buffer)
+		gzipWriter, _ := gzip.NewWriterLevel(buf, gzip.BestCompression)
+		_, err := gzipWriter.Write(target.content)
+		if err != nil {
+			return err
+		}
+		err = gzipWriter.Close()
+		if err != nil {
+			return err
+		}
+		if len(target.content) <= buf.Len() {
+			continue
+		}
+		// Store without compression since gzip is already compressed.
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Store,
+			Modified: target.header.Modified,
+			Extra:    hashExtra(buf.Bytes()),
+		}
+		header.SetMode(target.header.Mode())
+		zipFile, err := z.createHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = zipFile.Write(buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	z.gzipTargets = nil
+	return nil
+}
+
+// Create an entry in zip.
+// Entries must have unique names.
+func (z *Zipper) createHeader(header *zip.FileHeader) (io.Writer, error) {
+	if z.names[header.Name] {
+		return nil, fmt.Errorf("duplicate path in zip [%s]", header.Name)
+	}
+	z.names[header.Name] = true
+	return z.writer.CreateHeader(header)
+}
+
+// Create the extra fields holding SHA-256, SHA-384 and SHA-512 of the content.
+func hashExtra(content []byte) []byte {
+	sum := sha256.Sum256(content)
+	sum384 := sha512.Sum384(content)
+	sum512 := sha512.Sum512(content)
+	extra := make([]byte, 4, 8+len(sum)+len(sum384)+len(sum512))
+	binary.LittleEndian.PutUint16(extra[0:], HASH_EXTRA_ID)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(sum)))
+	extra = append(extra, sum[:]...)
+	extra = append(extra, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint16(extra[4+len(sum):], INTEGRITY_EXTRA_ID)
+	binary.LittleEndian.PutUint16(extra[6+len(sum):], uint16(len(sum384)+len(sum512)))
+	extra = append(extra, sum384[:]...)
+	return append(extra, sum512[:]...)
+}
+
+// Get the data of the extra field with the header ID.
+// Returns nil if the extra field holds no such data.
+func findExtra(extra []byte, id uint16) []byte {
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			return nil
+		}
+		if binary.LittleEndian.Uint16(extra[0:]) == id {
+			data := make([]byte, size)
+			copy(data, extra[4:4+size])
+			return data
+		}
+		extra = extra[4+size:]
+	}
+	return nil
+}
+
+// Get SHA-256 in the extra field.
+// Returns nil if the extra field holds no hash.
+func parseHashExtra(extra []byte) []byte {
+	hash := findExtra(extra, HASH_EXTRA_ID)
+	if len(hash) != sha256.Size {
+		return nil
+	}
+	return hash
+}
+
+// Get SHA-384 and SHA-512 in the extra field.
+// Returns nil if the extra field holds no digests.
+func parseIntegrityExtra(extra []byte) ([]byte, []byte) {
+	digests := findExtra(extra, INTEGRITY_EXTRA_ID)
+	if len(digests) != sha512.Size384+sha512.Size {
+		return nil, nil
+	}
+	return digests[:sha512.Size384], digests[sha512.Size384:]
+}
+
+// Check if the path matches any of the exclude patterns.
+// Patterns are matched against the base name, the path relative to the
+// added directory and the source path.
+func isExcluded(srcPath, relPath string, patterns []string) bool {
+	srcPath = filepath.ToSlash(srcPath)
+	relPath = filepath.ToSlash(relPath)
+	baseName := path.Base(srcPath)
+	for _, pattern := range patterns {
+		targets := []string{baseName, srcPath}
+		if relPath != "" {
+			targets = append(targets, relPath)
+		}
+		for _, target := range targets {
+			if matched, _ := path.Match(pattern, target); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Clean the destination path in the zip.
+func cleanDestPath(destPath string) (string, error) {
+	cleaned := path.Clean(strings.Replace(destPath, `\`, "/", -1))
+	if path.IsAbs(cleaned) || filepath.IsAbs(destPath) ||
+		cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid destination path [%s]", destPath)
+	}
+	return cleaned, nil
+}